@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// configCommand runs "config validate" and "config print"
+// and returns the exit code.
+func configCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	sub, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("config "+sub, flag.ExitOnError)
+	opts := addFlags(fs)
+	_ = fs.Parse(args)
+
+	switch sub {
+	case "validate":
+		cfg, err := opts.load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to load config:\n%v\n", opts.path(), err)
+			return 1
+		}
+
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid config:\n%v\n", opts.path(), err)
+			return 1
+		}
+
+		fmt.Printf("%s: config is valid\n", opts.path())
+		return 0
+	case "print":
+		cfg, err := opts.load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to load config:\n%v\n", opts.path(), err)
+			return 1
+		}
+
+		// the effective config: file, LB_* variables and flags merged
+		out, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		fmt.Println(string(out))
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config command %q\n\n%s", sub, usage)
+		return 2
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+
+	appconfig "github.com/0x0FACED/load-balancer/config"
+)
+
+// options are the flags shared by serve and config commands.
+type options struct {
+	configPath string
+	logLevel   string
+}
+
+func addFlags(fs *flag.FlagSet) *options {
+	var opts options
+	fs.StringVar(&opts.configPath, "config", "", "config file (.json, .yaml or .toml), overrides CONFIG_PATH")
+	fs.StringVar(&opts.logLevel, "log-level", "", "log level, overrides logger.level")
+	return &opts
+}
+
+// path returns the config path from the flag or CONFIG_PATH.
+func (o *options) path() string {
+	if o.configPath != "" {
+		return o.configPath
+	}
+	return appconfig.Path()
+}
+
+// load reads the config with the flags applied.
+func (o *options) load() (*appconfig.AppConfig, error) {
+	cfg, err := appconfig.LoadFile(o.path())
+	if err != nil {
+		return nil, err
+	}
+
+	o.apply(cfg)
+	return cfg, nil
+}
+
+// apply overrides config values set by flags.
+func (o *options) apply(cfg *appconfig.AppConfig) {
+	if o.logLevel != "" {
+		cfg.Logger.Level = o.logLevel
+	}
+}
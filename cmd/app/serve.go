@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	appconfig "github.com/0x0FACED/load-balancer/config"
+	"github.com/0x0FACED/load-balancer/internal/admin"
+	"github.com/0x0FACED/load-balancer/internal/app"
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/client"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/load-balancer/internal/middleware"
+	"github.com/0x0FACED/zlog"
+)
+
+// serve loads config and runs the load balancer until SIGINT or SIGTERM.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	opts := addFlags(fs)
+	_ = fs.Parse(args)
+
+	// graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// app config load
+	cfg, err := opts.load()
+	if err != nil {
+		log.Fatalf("Failed to load config:\n%v", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+
+	// logger init, the level is set globally to be changed on reload
+	logger, err := zlog.NewZerologLogger(zlog.LoggerConfig{
+		LogLevel: "trace",
+		LogsDir:  cfg.Logger.LogsDir,
+	})
+	if err != nil {
+		log.Fatalln("Failed to initialize logger:", err)
+	}
+
+	if err := app.SetLogLevel(cfg.Logger.Level); err != nil {
+		log.Fatalln("Failed to set log level:", err)
+	}
+
+	logger.Info().Msg("Logger initialized")
+
+	logger.Info().Str("version", version).Str("path", opts.path()).Msg("Starting load balancer")
+	logger.Info().Any("config", cfg.Redacted()).Msg("Loaded configuration")
+
+	// creating logger for different components
+	appLogger := logger.ChildWithName("component", "app")
+	middlewareLogger := logger.ChildWithName("component", "middleware")
+	balancerLogger := logger.ChildWithName("component", "balancer")
+
+	// init balancer
+	var bal balancer.Balancer
+	if len(cfg.Routes) > 0 {
+		// routes select one of the named pools, top level balancer is the default pool
+		bal, err = balancer.NewRouter(balancerLogger, cfg.Routes, cfg.Pools, cfg.Balancer)
+		if err != nil {
+			appLogger.Fatal().Err(err).Msg("Failed to create router")
+		}
+
+		appLogger.Info().Int("routes", len(cfg.Routes)).Int("pools", len(cfg.Pools)).Msg("Using router")
+	} else {
+		bal, err = balancer.New(balancerLogger, cfg.Balancer)
+		if err != nil {
+			appLogger.Fatal().Err(err).Msg("Failed to create balancer")
+		}
+
+		appLogger.Info().Msgf("Using %s balancer", cfg.Balancer.Type)
+	}
+
+	// init database
+	db, err := sql.Open("postgres", cfg.Database.DSN)
+	if err != nil {
+		appLogger.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+
+	// TODO: remove
+	clientRepo := client.NewPostgresRepo(db)
+
+	limiter, err := limiter.New(logger.ChildWithName("component", "limiter"), clientRepo, cfg.RateLimiter, cfg.Redis)
+	if err != nil {
+		appLogger.Fatal().Err(err).Msg("Failed to create rate limiter")
+	}
+
+	loggerMiddleware := middleware.NewLoggerMiddleware(middlewareLogger)
+	proxyMiddleware := middleware.NewProxyMiddleware(bal, cfg.Proxy)
+	limitterMiddleware := middleware.NewRateLimiterMiddleware(limiter, cfg.RateLimiter.Costs)
+
+	mux := http.NewServeMux()
+
+	handler := loggerMiddleware.Logger(limitterMiddleware.Limiter(proxyMiddleware.Proxy(mux)))
+
+	// основной сервер
+	srv := &http.Server{
+		Addr:         cfg.Server.Host + ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:      handler,
+		ReadTimeout:  cfg.Server.ReadTimeout.Duration(),
+		WriteTimeout: cfg.Server.WriteTimeout.Duration(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Duration(),
+	}
+
+	// admin API on its own listener
+	var adminSrv *http.Server
+	if cfg.Admin.Port != 0 {
+		pools := map[string]balancer.Balancer{balancer.DefaultPool: bal}
+		if router, ok := bal.(*balancer.Router); ok {
+			pools = router.Pools()
+		}
+
+		adminHandler := admin.NewAdminHandler(logger.ChildWithName("component", "admin"), pools, cfg.Admin)
+		adminHandler.SetLimiter(limiter)
+		adminHandler.SetBaseContext(ctx)
+
+		adminMux := http.NewServeMux()
+		adminHandler.RegisterRoutes(adminMux)
+
+		// SIGUSR1 drains backends listed in the drain file
+		go adminHandler.WatchDrainSignal(ctx)
+
+		adminSrv = &http.Server{
+			Addr:    cfg.Admin.Addr(),
+			Handler: adminMux,
+		}
+	}
+
+	app := app.New(srv, adminSrv, limiter, bal, appLogger, *cfg)
+
+	// config is reloaded on SIGHUP and when the file is changed
+	watcher := appconfig.NewWatcher(logger.ChildWithName("component", "config"), opts.path())
+	// flags take precedence over the reloaded file too
+	watcher.SetOverride(opts.apply)
+	go watcher.Watch(ctx, app.Reload)
+
+	go func() {
+		if err := app.Start(ctx); err != nil {
+			appLogger.Error().Err(err).Msg("Failed to start application")
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+
+	if err := app.Shutdown(); err != nil {
+		return
+	}
+
+	proxyMiddleware.Close()
+
+}
+
+func extractHostPort(backendURL string) (string, error) {
+	u, err := url.Parse(backendURL)
+	if err != nil {
+		return "", err
+	}
+	return u.Host, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is set at build time:
+//
+//	go build -ldflags "-X main.version=v1.2.0" ./cmd/app
+var version = "dev"
+
+func printVersion() {
+	fmt.Printf("load-balancer %s", version)
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		var revision, modified string
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value
+			}
+		}
+
+		if revision != "" {
+			fmt.Printf(" (%.12s", revision)
+			if modified == "true" {
+				fmt.Print(", modified")
+			}
+			fmt.Print(")")
+		}
+	}
+
+	fmt.Printf(" %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
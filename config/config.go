@@ -1,66 +1,163 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
+	"slices"
 
+	"github.com/0x0FACED/load-balancer/internal/admin"
 	"github.com/0x0FACED/load-balancer/internal/balancer"
 	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/load-balancer/internal/middleware"
+	"github.com/0x0FACED/load-balancer/internal/pkg/duration"
+	"github.com/0x0FACED/load-balancer/internal/pkg/validate"
+	"github.com/rs/zerolog"
 )
 
 type AppConfig struct {
-	Balancer    balancer.Config `json:"balancer"`
-	RateLimiter limiter.Config  `json:"rate_limiter"`
-	Logger      LoggerConfig    `json:"logger"`
-	Server      ServerConfig    `json:"server"`
-	Database    DatabaseConfig  `json:"database"`
-	Redis       RedisConfig     `json:"redis"`
+	Balancer    balancer.Config        `json:"balancer"`
+	RateLimiter limiter.Config         `json:"rate_limiter"`
+	Proxy       middleware.ProxyConfig `json:"proxy"`
+	Logger      LoggerConfig           `json:"logger"`
+	Server      ServerConfig           `json:"server"`
+	Admin       admin.Config           `json:"admin"`
+	Database    DatabaseConfig         `json:"database"`
+	Redis       RedisConfig            `json:"redis"`
+
+	// Pools are named groups of backends, each with its own balancer config.
+	Pools map[string]balancer.Config `json:"pools"`
+	// Routes select a pool for the request, first match wins.
+	// Requests without a match go to the top level balancer.
+	Routes []balancer.RouteConfig `json:"routes"`
 }
 
 type ServerConfig struct {
-	Host         string `json:"host"`
-	Port         int    `json:"port"`
-	ReadTimeout  int    `json:"read_timeout"`
-	WriteTimeout int    `json:"write_timeout"`
-	IdleTimeout  int    `json:"idle_timeout"`
+	Host         string                `json:"host"`
+	Port         int                   `json:"port"`
+	ReadTimeout  duration.Milliseconds `json:"read_timeout"`
+	WriteTimeout duration.Milliseconds `json:"write_timeout"`
+	IdleTimeout  duration.Milliseconds `json:"idle_timeout"`
 }
 
 type DatabaseConfig struct {
 	DSN string `json:"dsn"`
 }
 
-type RedisConfig struct {
-	Address      string `json:"address"`
-	Password     string `json:"password"`
-	DB           int    `json:"db"`
-	PoolSize     int    `json:"pool_size"`
-	DialTimeout  int    `json:"dial_timeout"`
-	ReadTimeout  int    `json:"read_timeout"`
-	WriteTimeout int    `json:"write_timeout"`
-}
+// RedisConfig is the connection of the redis rate limiter.
+type RedisConfig = limiter.RedisConfig
 
 type LoggerConfig struct {
 	Level   string `json:"level"`
 	LogsDir string `json:"logs_dir"`
 }
 
-func Load() (*AppConfig, error) {
-	var cfg AppConfig
-
+// Path returns the config file path from CONFIG_PATH,
+// ./config/config.json by default. The file can be .json, .yaml or .toml.
+func Path() string {
 	path := os.Getenv("CONFIG_PATH")
 	if path == "" {
 		path = "./config/config.json"
 	}
+	return path
+}
 
-	cfgFile, err := os.Open(path)
+// Load reads config from Path.
+func Load() (*AppConfig, error) {
+	return LoadFile(Path())
+}
+
+// LoadFile reads config from the file at path in JSON, YAML or TOML
+// by its extension, with LB_* environment variables over it. Unknown keys
+// are errors, so typos don't silently leave the defaults.
+func LoadFile(path string) (*AppConfig, error) {
+	var cfg AppConfig
+
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer cfgFile.Close()
 
-	if err := json.NewDecoder(cfgFile).Decode(&cfg); err != nil {
+	raw, err := parse(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := applyEnv(raw, os.Environ()); err != nil {
+		return nil, err
+	}
+
+	if err := decode(raw, &cfg); err != nil {
 		return nil, err
 	}
 
 	return &cfg, nil
 }
+
+// Validate checks the whole config and reports every problem
+// with the JSON path of the field, one per line.
+func (c *AppConfig) Validate() error {
+	errs := []error{
+		validate.Field("server", c.Server.Validate()),
+		validate.Field("rate_limiter", c.RateLimiter.Validate()),
+		validate.Field("proxy", c.Proxy.Validate()),
+		validate.Field("logger", c.Logger.Validate()),
+		validate.Field("admin", c.Admin.Validate()),
+		validate.Field("redis", c.Redis.Validate()),
+	}
+
+	if c.RateLimiter.Type == limiter.Redis && c.Redis.Address == "" {
+		errs = append(errs, validate.Errorf("redis.address", "must be set for rate_limiter.type %q", limiter.Redis))
+	}
+
+	// with routes the top level balancer is only used if it has backends
+	if len(c.Routes) == 0 || len(c.Balancer.Backends) > 0 {
+		errs = append(errs, validate.Field("balancer", c.Balancer.Validate()))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Pools)) {
+		path := "pools." + name
+		if name == balancer.DefaultPool && len(c.Balancer.Backends) > 0 {
+			errs = append(errs, validate.Errorf(path, "pool name is reserved for the top level balancer"))
+		}
+		errs = append(errs, validate.Field(path, c.Pools[name].Validate()))
+	}
+
+	for i, route := range c.Routes {
+		path := validate.Index("routes", i)
+
+		_, ok := c.Pools[route.Pool]
+		if route.Pool == balancer.DefaultPool {
+			ok = len(c.Balancer.Backends) > 0
+		}
+		if !ok {
+			errs = append(errs, validate.Errorf(path+".pool", "unknown pool %q", route.Pool))
+		}
+
+		if route.Retry != nil {
+			errs = append(errs, validate.Field(path+".retry", route.Retry.Validate()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c ServerConfig) Validate() error {
+	return errors.Join(
+		validate.Range("port", c.Port, 1, 65535),
+		validate.NonNegative("read_timeout", c.ReadTimeout),
+		validate.NonNegative("write_timeout", c.WriteTimeout),
+		validate.NonNegative("idle_timeout", c.IdleTimeout),
+	)
+}
+
+func (c LoggerConfig) Validate() error {
+	if c.Level == "" {
+		return validate.Errorf("level", "must be set")
+	}
+	if _, err := zerolog.ParseLevel(c.Level); err != nil {
+		return validate.Field("level", err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/config"
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadString(t *testing.T, data string) (*config.AppConfig, error) {
+	t.Helper()
+	return loadFile(t, "config.json", data)
+}
+
+func loadFile(t *testing.T, name, data string) (*config.AppConfig, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	return config.LoadFile(path)
+}
+
+func TestLoadFile_Shipped(t *testing.T) {
+	cfg, err := config.LoadFile("config.json")
+	require.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoadFile_UnknownFields(t *testing.T) {
+	_, err := loadString(t, `{
+		"server": {"port": 8080, "max_connections": 100},
+		"pools": {"api": {"type": "round_robin", "helthcheck": {}}},
+		"routes": [{"pool": "api", "match": {"path": "/api"}}]
+	}`)
+	require.Error(t, err)
+
+	msg := err.Error()
+	assert.Contains(t, msg, "server.max_connections: unknown field")
+	assert.Contains(t, msg, "pools.api.helthcheck: unknown field")
+	assert.Contains(t, msg, "routes[0].match.path: unknown field")
+}
+
+func TestLoadFile_WrongType(t *testing.T) {
+	_, err := loadString(t, `{"server": {"port": "8080"}, "balancer": {"healthcheck": {"interval": "2x"}}}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "balancer.healthcheck.interval: time: unknown unit")
+
+	_, err = loadString(t, `{"server": {"port": "8080"}}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.port: expected int, got string")
+
+	_, err = loadString(t, "{\n\t\"server\": {\"port\": 8080,}\n}")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestLoadFile_Formats(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "config.json",
+			data: `{
+				"server": {"port": 8080, "read_timeout": "5s", "idle_timeout": 120000},
+				"balancer": {"type": "round_robin", "backends": ["http://app:8081"], "healthcheck": {"interval": "2s"}},
+				"rate_limiter": {"capacity": 10, "rate": 1, "refill_interval": "1s", "ttl": "1h"},
+				"routes": [{"pool": "default", "match": {"path_prefix": "/api/"}}]
+			}`,
+		},
+		{
+			name: "config.yaml",
+			data: `
+server:
+  port: 8080
+  read_timeout: 5s
+  idle_timeout: 120000
+balancer:
+  type: round_robin
+  backends: [http://app:8081]
+  healthcheck:
+    interval: 2s
+rate_limiter:
+  capacity: 10
+  rate: 1
+  refill_interval: 1s
+  ttl: 1h
+routes:
+  - pool: default
+    match:
+      path_prefix: /api/
+`,
+		},
+		{
+			name: "config.toml",
+			data: `
+[server]
+port = 8080
+read_timeout = "5s"
+idle_timeout = 120000
+
+[balancer]
+type = "round_robin"
+backends = ["http://app:8081"]
+healthcheck = { interval = "2s" }
+
+[rate_limiter]
+capacity = 10
+rate = 1
+refill_interval = "1s"
+ttl = "1h"
+
+[[routes]]
+pool = "default"
+match = { path_prefix = "/api/" }
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadFile(t, tt.name, tt.data)
+			require.NoError(t, err)
+
+			assert.Equal(t, 8080, cfg.Server.Port)
+			assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout.Duration())
+			assert.Equal(t, 2*time.Minute, cfg.Server.IdleTimeout.Duration())
+			assert.Equal(t, balancer.RoundRobin, cfg.Balancer.Type)
+			assert.Equal(t, []string{"http://app:8081"}, cfg.Balancer.Backends)
+			assert.Equal(t, 2*time.Second, cfg.Balancer.HealthCheck.Interval.Duration())
+			assert.Equal(t, time.Hour, cfg.RateLimiter.TTL.Duration())
+			require.Len(t, cfg.Routes, 1)
+			assert.Equal(t, "/api/", cfg.Routes[0].Match.PathPrefix)
+		})
+	}
+
+	_, err := loadFile(t, "config.ini", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported config format")
+
+	_, err = loadFile(t, "config.yaml", "server:\n  port: 8080\n  max_connections: 100\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.max_connections: unknown field")
+}
+
+func TestLoadFile_EnvOverrides(t *testing.T) {
+	t.Setenv("LB_BALANCER_TYPE", "least_conn")
+	t.Setenv("LB_BALANCER_BACKENDS", "http://app:8081, http://app:8082")
+	t.Setenv("LB_BALANCER_HEALTHCHECK_BODY_REGEX", "ok")
+	t.Setenv("LB_REDIS_PASSWORD", "secret")
+	t.Setenv("LB_RATE_LIMITER_REFILL_INTERVAL", "500ms")
+	t.Setenv("LB_POOLS_API_READ_HEALTHCHECK_INTERVAL", "3000")
+	t.Setenv("LB_PROXY_HTTP2", "true")
+	t.Setenv("LB_PROXY_DIAL_TIMEOUT", "5s")
+	t.Setenv("LB_ADMIN_DRAIN_TIMEOUT", "1m")
+	t.Setenv("LB_BALANCER_OUTLIER_BASE_EJECTION_TIME", "10s")
+
+	cfg, err := loadString(t, `{
+		"balancer": {"type": "round_robin", "healthcheck": {"body": "pong"}},
+		"redis": {"address": "redis:6379", "password": ""},
+		"pools": {"api-read": {"type": "p2c"}}
+	}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, balancer.LeastConn, cfg.Balancer.Type)
+	assert.Equal(t, []string{"http://app:8081", "http://app:8082"}, cfg.Balancer.Backends)
+	assert.Equal(t, "pong", cfg.Balancer.HealthCheck.Body)
+	assert.Equal(t, "ok", cfg.Balancer.HealthCheck.BodyRegex)
+	assert.Equal(t, "redis:6379", cfg.Redis.Address)
+	assert.Equal(t, "secret", cfg.Redis.Password)
+	assert.Equal(t, 500*time.Millisecond, cfg.RateLimiter.RefillIntrerval.Duration())
+	assert.Equal(t, 3*time.Second, cfg.Pools["api-read"].HealthCheck.Interval.Duration())
+	assert.True(t, cfg.Proxy.HTTP2)
+	assert.Equal(t, 5*time.Second, cfg.Proxy.DialTimeout.Duration())
+	assert.Equal(t, time.Minute, cfg.Admin.DrainTimeout.Duration())
+	assert.Equal(t, 10*time.Second, cfg.Balancer.Outlier.BaseEjectionTime.Duration())
+
+	t.Setenv("LB_SERVER_MAX_CONNECTIONS", "100")
+	t.Setenv("LB_SERVER_PORT", "http")
+	_, err = loadString(t, `{}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LB_SERVER_MAX_CONNECTIONS: unknown config field")
+	assert.Contains(t, err.Error(), `LB_SERVER_PORT: expected integer, got "http"`)
+}
+
+func validConfig() config.AppConfig {
+	var cfg config.AppConfig
+	cfg.Server.Port = 8080
+	cfg.Balancer.Type = balancer.RoundRobin
+	cfg.Balancer.Backends = []string{"http://app:8081"}
+	cfg.RateLimiter.Capacity = 10
+	cfg.RateLimiter.Rate = 1
+	cfg.RateLimiter.RefillIntrerval = 1000
+	cfg.Logger.Level = "info"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	cfg := validConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Server.Port = 0
+	cfg.Balancer.Type = "random"
+	cfg.Balancer.Backends = []string{"http://app:8081", "app:8082", "http://app:8081"}
+	cfg.Balancer.HealthCheck.Interval = -1
+	cfg.RateLimiter.RefillIntrerval = -1
+	cfg.RateLimiter.Algorithm = "leaky_bucket"
+	cfg.RateLimiter.Costs = []limiter.CostRule{{Path: "/export/*", Cost: 50}, {Path: "/export/[", Cost: 0}}
+	cfg.Logger.Level = "verbose"
+	cfg.Pools = map[string]balancer.Config{
+		"api": {
+			Type:     balancer.LeastConn,
+			Backends: []string{"http://api:8080"},
+			Retry:    balancer.RetryConfig{BudgetPercent: 120, RetryOnStatus: []int{503, 1000}},
+		},
+	}
+	cfg.Routes = []balancer.RouteConfig{{Pool: "api"}, {Pool: "apii"}}
+
+	err := cfg.Validate()
+	require.Error(t, err)
+
+	for _, want := range []string{
+		"server.port: ",
+		"balancer.type: ",
+		"balancer.backends[1]: ",
+		"balancer.backends[2]: duplicate",
+		"balancer.healthcheck.interval: ",
+		"rate_limiter.refill_interval: ",
+		"rate_limiter.algorithm: ",
+		"rate_limiter.costs[1].path: ",
+		"rate_limiter.costs[1].cost: ",
+		"logger.level: ",
+		"pools.api.retry.budget_percent: ",
+		"pools.api.retry.retry_on_status[1]: ",
+		"routes[1].pool: unknown pool",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+	assert.NotContains(t, err.Error(), "routes[0]")
+	assert.NotContains(t, err.Error(), "costs[0]")
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := validConfig()
+	cfg.Redis.Password = "hunter2"
+	cfg.Admin.Token = "hunter2"
+	cfg.Database.DSN = "postgres://user:hunter2@db:5432/db?sslmode=disable"
+
+	r := cfg.Redacted()
+	assert.Equal(t, "xxxxx", r.Redis.Password)
+	assert.Equal(t, "postgres://user:xxxxx@db:5432/db?sslmode=disable", r.Database.DSN)
+	assert.Equal(t, "xxxxx", r.Admin.Token)
+	assert.Equal(t, "hunter2", cfg.Redis.Password, "original config is not changed")
+
+	cfg.Database.DSN = "host=db user=user password=hunter2 dbname=db"
+	assert.Equal(t, "host=db user=user password=xxxxx dbname=db", cfg.Redacted().Database.DSN)
+}
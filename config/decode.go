@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/validate"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// parse decodes the config file by its extension into a generic
+// JSON-like tree: map[string]any, []any, strings, float64 and bool.
+func parse(path string, data []byte) (map[string]any, error) {
+	var raw any
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		if err := json.Unmarshal(data, &raw); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line, col := position(data, syntaxErr.Offset)
+				return nil, fmt.Errorf("line %d, column %d: %w", line, col, err)
+			}
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		var doc map[string]any
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		raw = doc
+	default:
+		return nil, fmt.Errorf("unsupported config format %q, use .json, .yaml or .toml", ext)
+	}
+
+	if raw == nil {
+		// empty yaml document
+		return map[string]any{}, nil
+	}
+
+	// yaml and toml have their own types for numbers, dates and
+	// lists of tables, the JSON round trip brings them to one tree
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+
+	obj, ok := tree.(map[string]any)
+	if !ok {
+		return nil, errors.New("config must be an object")
+	}
+	return obj, nil
+}
+
+// decode decodes the tree into cfg strictly: unknown keys and values
+// of wrong types are reported with their JSON paths, all at once.
+func decode(raw map[string]any, cfg *AppConfig) error {
+	if err := errors.Join(checkFields(raw, reflect.TypeOf(cfg), "")...); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return validate.Errorf(typeErr.Field, "expected %s, got %s", typeErr.Type, typeErr.Value)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkFields returns errors for keys of raw that have no field in t
+// and for values rejected by the fields' own decoders, like durations.
+func checkFields(raw any, t reflect.Type, path string) []error {
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		data, err := json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(data, reflect.New(t).Interface())
+		}
+		if err != nil {
+			return []error{validate.Field(path, err)}
+		}
+		return nil
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var errs []error
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(obj) {
+			field, ok := fields[strings.ToLower(key)]
+			if !ok {
+				errs = append(errs, validate.Errorf(fieldPath(path, key), "unknown field"))
+				continue
+			}
+			errs = append(errs, checkFields(obj[key], field.Type, fieldPath(path, key))...)
+		}
+	case reflect.Map:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(obj) {
+			errs = append(errs, checkFields(obj[key], t.Elem(), fieldPath(path, key))...)
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := raw.([]any)
+		if !ok {
+			return nil
+		}
+		for i, v := range list {
+			errs = append(errs, checkFields(v, t.Elem(), validate.Index(path, i))...)
+		}
+	}
+
+	return errs
+}
+
+// jsonFields returns fields of struct t by lowercased JSON name,
+// encoding/json matches keys case-insensitively.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f
+	}
+	return fields
+}
+
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func fieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// position returns line and column of the byte offset in data.
+func position(data []byte, offset int64) (line, col int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
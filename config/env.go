@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts environment variables which override values
+// from the config file, like LB_BALANCER_TYPE or LB_REDIS_PASSWORD.
+const EnvPrefix = "LB_"
+
+// applyEnv sets values of LB_* variables from environ over the tree
+// decoded from the file. Variable name is the path of the field in
+// upper case with "_" between keys, e.g. LB_POOLS_API_HEALTHCHECK_INTERVAL.
+// Lists are comma separated or JSON, objects are JSON.
+func applyEnv(raw map[string]any, environ []string) error {
+	environ = slices.Sorted(slices.Values(environ))
+
+	var errs []error
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		key, ok := strings.CutPrefix(name, EnvPrefix)
+		if !ok {
+			continue
+		}
+
+		if _, err := setEnv(raw, reflect.TypeFor[AppConfig](), key, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// setEnv sets value at the field of type t addressed by key in node
+// and returns the updated node.
+func setEnv(node any, t reflect.Type, key, value string) (any, error) {
+	for t.Kind() == reflect.Pointer && !reflect.PointerTo(t).Implements(unmarshalerType) {
+		t = t.Elem()
+	}
+
+	if key == "" {
+		return envValue(value, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj := asObject(node)
+
+		var (
+			name  string
+			field reflect.StructField
+		)
+		// the longest matching name wins: BODY_REGEX is body_regex, not body
+		for n, f := range jsonFields(t) {
+			if matchesEnv(key, n) && len(n) > len(name) {
+				name, field = n, f
+			}
+		}
+		if name == "" {
+			return node, errors.New("unknown config field")
+		}
+
+		k := objectKey(obj, name)
+		v, err := setEnv(obj[k], field.Type, envRest(key, name), value)
+		if err != nil {
+			return node, err
+		}
+		obj[k] = v
+		return obj, nil
+	case reflect.Map:
+		obj := asObject(node)
+
+		// map keys are free-form, only keys from the file can be addressed
+		var name string
+		for k := range obj {
+			if matchesEnv(key, k) && len(k) > len(name) {
+				name = k
+			}
+		}
+		if name == "" {
+			return node, errors.New("no such key in the config file")
+		}
+
+		v, err := setEnv(obj[name], t.Elem(), envRest(key, name), value)
+		if err != nil {
+			return node, err
+		}
+		obj[name] = v
+		return obj, nil
+	case reflect.Slice:
+		list, _ := node.([]any)
+
+		idx, rest, _ := strings.Cut(key, "_")
+		i, err := strconv.Atoi(idx)
+		if err != nil || i < 0 || i >= len(list) {
+			return node, fmt.Errorf("no element %s in the config file", idx)
+		}
+
+		v, err := setEnv(list[i], t.Elem(), rest, value)
+		if err != nil {
+			return node, err
+		}
+		list[i] = v
+		return list, nil
+	default:
+		return node, errors.New("unknown config field")
+	}
+}
+
+// envValue converts value of the variable to the tree value for type t.
+func envValue(value string, t reflect.Type) (any, error) {
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		// durations are numbers in their unit or strings like "2s"
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return json.Number(value), nil
+		}
+		return value, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("expected integer, got %q", value)
+		}
+		return json.Number(value), nil
+	case reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("expected number, got %q", value)
+		}
+		return json.Number(value), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected bool, got %q", value)
+		}
+		return b, nil
+	}
+
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	if t.Kind() == reflect.Slice {
+		list := []any{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := envValue(item, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+
+	return nil, fmt.Errorf("expected JSON object, got %q", value)
+}
+
+// matchesEnv reports whether the variable key addresses name or its child.
+func matchesEnv(key, name string) bool {
+	name = envName(name)
+	return key == name || strings.HasPrefix(key, name+"_")
+}
+
+// envRest returns the part of the variable key after name.
+func envRest(key, name string) string {
+	return strings.TrimPrefix(key[len(envName(name)):], "_")
+}
+
+func envName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+func asObject(node any) map[string]any {
+	if obj, ok := node.(map[string]any); ok {
+		return obj
+	}
+	return map[string]any{}
+}
+
+// objectKey returns the key of obj which encoding/json would decode
+// into the field name, name if there is none.
+func objectKey(obj map[string]any, name string) string {
+	for k := range obj {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
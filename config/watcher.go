@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/0x0FACED/zlog"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce groups file events of one save (editors often
+// truncate and write, or write a temp file and rename it).
+const reloadDebounce = 200 * time.Millisecond
+
+// Watcher reloads the config file on SIGHUP and when the file is changed.
+type Watcher struct {
+	path string
+	// override is applied to every loaded config before it is validated
+	override func(*AppConfig)
+	log      *zlog.ZerologLogger
+}
+
+func NewWatcher(log *zlog.ZerologLogger, path string) *Watcher {
+	return &Watcher{
+		path: path,
+		log:  log,
+	}
+}
+
+// SetOverride sets fn to change every reloaded config before it is
+// validated, e.g. to apply command line flags over the file.
+func (w *Watcher) SetOverride(fn func(*AppConfig)) {
+	w.override = fn
+}
+
+// Watch loads and validates the config on every change and passes it
+// to apply. Invalid configs are logged and skipped, so the running config
+// stays in place. It blocks until ctx is done.
+func (w *Watcher) Watch(ctx context.Context, apply func(*AppConfig) error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.log.Error().Err(err).Msg("[ConfigWatcher] file watcher failed, reload on SIGHUP only")
+	} else {
+		defer fw.Close()
+
+		// the directory is watched, not the file: editors and configmaps
+		// replace the file, and the watch on the old one is lost
+		if err := fw.Add(filepath.Dir(w.path)); err != nil {
+			w.log.Error().Err(err).Str("path", w.path).Msg("[ConfigWatcher] file watcher failed, reload on SIGHUP only")
+		} else {
+			events, errs = fw.Events, fw.Errors
+		}
+	}
+
+	w.log.Info().Str("path", w.path).Msg("[ConfigWatcher] watching config")
+
+	debounce := time.NewTimer(0)
+	<-debounce.C
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.log.Info().Msg("[ConfigWatcher] SIGHUP received")
+			w.reload(apply)
+		case ev := <-events:
+			if !w.affects(ev) {
+				continue
+			}
+			debounce.Reset(reloadDebounce)
+		case err := <-errs:
+			w.log.Warn().Err(err).Msg("[ConfigWatcher] file watcher error")
+		case <-debounce.C:
+			w.log.Info().Str("path", w.path).Msg("[ConfigWatcher] config file changed")
+			w.reload(apply)
+		}
+	}
+}
+
+// affects reports whether ev changes the config file. Kubernetes
+// configmaps are updated by swapping the ..data symlink.
+func (w *Watcher) affects(ev fsnotify.Event) bool {
+	if !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Rename) {
+		return false
+	}
+
+	name := filepath.Base(ev.Name)
+	return name == filepath.Base(w.path) || name == "..data"
+}
+
+func (w *Watcher) reload(apply func(*AppConfig) error) {
+	cfg, err := LoadFile(w.path)
+	if err != nil {
+		w.log.Error().Err(err).Str("path", w.path).Msg("[ConfigWatcher] failed to load config, keeping the current one")
+		return
+	}
+
+	if w.override != nil {
+		w.override(cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		w.log.Error().Err(err).Str("path", w.path).Msg("[ConfigWatcher] invalid config, keeping the current one")
+		return
+	}
+
+	if err := apply(cfg); err != nil {
+		w.log.Error().Err(err).Msg("[ConfigWatcher] failed to apply config")
+		return
+	}
+
+	w.log.Info().Msg("[ConfigWatcher] config reloaded")
+}
@@ -0,0 +1,89 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/config"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, path, level string) {
+	t.Helper()
+
+	data := `{
+		"server": {"port": 8080},
+		"balancer": {"type": "round_robin"},
+		"rate_limiter": {"capacity": 10, "rate": 1, "refill_interval": 1000},
+		"logger": {"level": "` + level + `"}
+	}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+}
+
+func TestWatcher_ReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, "info")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan *config.AppConfig, 10)
+	w := config.NewWatcher(zlog.NewTestLogger(), path)
+	go w.Watch(ctx, func(cfg *config.AppConfig) error {
+		applied <- cfg
+		return nil
+	})
+
+	// give the watcher time to subscribe
+	time.Sleep(100 * time.Millisecond)
+
+	writeConfig(t, path, "verbose")
+	select {
+	case <-applied:
+		t.Fatal("invalid config must not be applied")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	writeConfig(t, path, "debug")
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, "debug", cfg.Logger.Level)
+	case <-time.After(2 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
+
+func TestWatcher_OverrideBeforeValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, "info")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan *config.AppConfig, 10)
+	w := config.NewWatcher(zlog.NewTestLogger(), path)
+	w.SetOverride(func(cfg *config.AppConfig) {
+		cfg.Logger.Level = "debug"
+	})
+	go w.Watch(ctx, func(cfg *config.AppConfig) error {
+		applied <- cfg
+		return nil
+	})
+
+	// give the watcher time to subscribe
+	time.Sleep(100 * time.Millisecond)
+
+	// the level in the file is invalid, but it is overridden
+	writeConfig(t, path, "verbose")
+	select {
+	case cfg := <-applied:
+		assert.Equal(t, "debug", cfg.Logger.Level)
+	case <-time.After(2 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
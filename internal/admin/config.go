@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/duration"
+	"github.com/0x0FACED/load-balancer/internal/pkg/validate"
+)
+
+// defaultHost keeps the admin API on loopback unless the host is set.
+const defaultHost = "127.0.0.1"
+
+// Config is the listener of the admin API, it is disabled if Port is 0.
+// It must not be reachable by clients.
+type Config struct {
+	// Host is 127.0.0.1 by default, so the API is local only.
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// Token is required as "Authorization: Bearer <token>" by every
+	// admin route if set.
+	Token string `json:"token"`
+	// DrainTimeout is the default time to wait for requests
+	// in flight of a draining backend, "30s" by default.
+	DrainTimeout duration.Milliseconds `json:"drain_timeout"`
+	// DrainFile lists addresses of backends to drain on SIGUSR1,
+	// one per line. Empty disables the signal.
+	DrainFile string `json:"drain_file"`
+}
+
+// Addr returns the address of the admin listener.
+func (c Config) Addr() string {
+	host := c.Host
+	if host == "" {
+		host = defaultHost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(c.Port))
+}
+
+// Validate checks the port and drain timeout.
+func (c Config) Validate() error {
+	return errors.Join(
+		validate.Range("port", c.Port, 0, 65535),
+		validate.NonNegative("drain_timeout", c.DrainTimeout),
+	)
+}
@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+)
+
+// ApplyDrainFile makes Config.DrainFile the set of draining backends:
+// listed backends of all pools are drained, draining backends which are
+// not listed anymore are enabled again. Disabled backends are not touched.
+// Empty lines and lines starting with # are ignored.
+func (h *AdminHandler) ApplyDrainFile(ctx context.Context) error {
+	listed, err := readDrainFile(h.cfg.DrainFile)
+	if err != nil {
+		return err
+	}
+
+	timeout := h.drainTimeout()
+	for name, bal := range h.pools {
+		log := h.log.ChildWithName("pool", name)
+
+		for _, bk := range bal.Backends() {
+			state := bk.AdminState()
+
+			switch {
+			case listed[bk.Addr] && state == balancer.AdminEnabled:
+				bk.SetAdminState(balancer.AdminDraining)
+				go func() {
+					_ = balancer.Drain(ctx, log, bk, timeout)
+				}()
+			case !listed[bk.Addr] && state == balancer.AdminDraining:
+				bk.SetAdminState(balancer.AdminEnabled)
+				log.Info().Str("addr", bk.Addr).Msg("[Admin] backend enabled, not in drain file")
+			}
+		}
+	}
+
+	return nil
+}
+
+func readDrainFile(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	listed := make(map[string]bool)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		listed[line] = true
+	}
+
+	return listed, scanner.Err()
+}
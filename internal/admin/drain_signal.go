@@ -0,0 +1,34 @@
+//go:build unix
+
+package admin
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchDrainSignal applies Config.DrainFile on every SIGUSR1 until ctx is done,
+// see ApplyDrainFile. It blocks, so it is meant to be run in its own goroutine.
+func (h *AdminHandler) WatchDrainSignal(ctx context.Context) {
+	if h.cfg.DrainFile == "" {
+		return
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			h.log.Info().Str("file", h.cfg.DrainFile).Msg("[Admin] drain signal received")
+			if err := h.ApplyDrainFile(ctx); err != nil {
+				h.log.Error().Err(err).Str("file", h.cfg.DrainFile).Msg("[Admin] failed to apply drain file")
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package admin
+
+import "context"
+
+// WatchDrainSignal does nothing, there is no SIGUSR1 on this platform.
+// Use the admin API to drain backends.
+func (h *AdminHandler) WatchDrainSignal(ctx context.Context) {}
@@ -0,0 +1,326 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/load-balancer/internal/pkg/httpcommon"
+	"github.com/0x0FACED/zlog"
+)
+
+const defaultDrainTimeout = 30 * time.Second
+
+var (
+	ErrUnknownPool  = errors.New("unknown pool")
+	ErrInvalidAddr  = errors.New("addr must be an absolute http or https url")
+	ErrUnauthorized = errors.New("invalid or missing bearer token")
+)
+
+// AdminHandler manages backends of the balancer pools at runtime.
+// It is meant to be served on a separate listener, not exposed to clients.
+type AdminHandler struct {
+	pools map[string]balancer.Balancer
+	// limiter reports rate limiter stats, nil if it is not set
+	limiter LimiterStats
+	// baseCtx bounds drains that run after the response is sent
+	baseCtx context.Context
+	cfg     Config
+	log     *zlog.ZerologLogger
+}
+
+// LimiterStats is the part of the rate limiter used by the admin API.
+type LimiterStats interface {
+	Stats() limiter.Stats
+}
+
+// NewAdminHandler creates handler over pools by name. Single balancer
+// without routes is passed as the balancer.DefaultPool pool.
+func NewAdminHandler(log *zlog.ZerologLogger, pools map[string]balancer.Balancer, cfg Config) *AdminHandler {
+	return &AdminHandler{
+		pools:   pools,
+		baseCtx: context.Background(),
+		cfg:     cfg,
+		log:     log,
+	}
+}
+
+// SetBaseContext sets the context of the server lifecycle. Drains
+// without wait stop waiting for the backend once it is done.
+func (h *AdminHandler) SetBaseContext(ctx context.Context) {
+	h.baseCtx = ctx
+}
+
+// SetLimiter makes the rate limiter stats available at GET /admin/limiter.
+func (h *AdminHandler) SetLimiter(l LimiterStats) {
+	h.limiter = l
+}
+
+func (h *AdminHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/backends", h.auth(h.List))
+	mux.HandleFunc("POST /admin/backends", h.auth(h.Add))
+	mux.HandleFunc("DELETE /admin/backends", h.auth(h.Remove))
+	mux.HandleFunc("POST /admin/backends/drain", h.auth(h.Drain))
+	mux.HandleFunc("POST /admin/backends/enable", h.auth(h.setState(balancer.AdminEnabled)))
+	mux.HandleFunc("POST /admin/backends/disable", h.auth(h.setState(balancer.AdminDisabled)))
+	mux.HandleFunc("GET /admin/limiter", h.auth(h.LimiterStats))
+}
+
+// auth rejects requests without the bearer token of Config.Token,
+// all requests pass if it is empty.
+func (h *AdminHandler) auth(next http.HandlerFunc) http.HandlerFunc {
+	if h.cfg.Token == "" {
+		return next
+	}
+
+	want := []byte(h.cfg.Token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpcommon.JSONError(w, http.StatusUnauthorized, ErrUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// LimiterStats returns the number of rate limiter buckets kept in memory
+// and how many of them were evicted.
+func (h *AdminHandler) LimiterStats(w http.ResponseWriter, r *http.Request) {
+	if h.limiter == nil {
+		httpcommon.JSONError(w, http.StatusNotFound, errors.New("rate limiter is not set"))
+		return
+	}
+
+	httpcommon.JSONResponse(w, http.StatusOK, h.limiter.Stats())
+}
+
+// List returns backends of all pools or of the pool from the query.
+func (h *AdminHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter := r.URL.Query().Get("pool")
+
+	names := make([]string, 0, len(h.pools))
+	for name := range h.pools {
+		if filter == "" || filter == name {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	if filter != "" && len(names) == 0 {
+		httpcommon.JSONError(w, http.StatusNotFound, ErrUnknownPool)
+		return
+	}
+
+	backends := make([]BackendInfo, 0)
+	for _, name := range names {
+		for _, bk := range h.pools[name].Backends() {
+			backends = append(backends, newBackendInfo(name, bk))
+		}
+	}
+
+	httpcommon.JSONResponse(w, http.StatusOK, backends)
+}
+
+// Add adds backend to the pool and starts its health check.
+func (h *AdminHandler) Add(w http.ResponseWriter, r *http.Request) {
+	var req BackendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpcommon.JSONError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	if err := validateAddr(req.Addr); err != nil {
+		httpcommon.JSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	pool, bal, err := h.pool(req.Pool)
+	if err != nil {
+		httpcommon.JSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	m, ok := bal.(balancer.BackendManager)
+	if !ok {
+		httpcommon.JSONError(w, http.StatusNotImplemented, balancer.ErrNotManageable)
+		return
+	}
+
+	bk, err := m.AddBackend(req.Addr, req.Weight)
+	if err != nil {
+		httpcommon.JSONError(w, statusFromError(err), err)
+		return
+	}
+
+	h.log.Info().Str("pool", pool).Str("addr", req.Addr).Msg("[Admin] backend added")
+	httpcommon.JSONResponse(w, http.StatusCreated, newBackendInfo(pool, bk))
+}
+
+// Remove removes backend selected by the addr and pool query parameters.
+func (h *AdminHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	addr := r.URL.Query().Get("addr")
+	if addr == "" {
+		httpcommon.JSONError(w, http.StatusBadRequest, errors.New("addr is required"))
+		return
+	}
+
+	pool, bal, err := h.pool(r.URL.Query().Get("pool"))
+	if err != nil {
+		httpcommon.JSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	m, ok := bal.(balancer.BackendManager)
+	if !ok {
+		httpcommon.JSONError(w, http.StatusNotImplemented, balancer.ErrNotManageable)
+		return
+	}
+
+	if err := m.RemoveBackend(addr); err != nil {
+		httpcommon.JSONError(w, statusFromError(err), err)
+		return
+	}
+
+	h.log.Info().Str("pool", pool).Str("addr", addr).Msg("[Admin] backend removed")
+	httpcommon.EmptyResponse(w, http.StatusNoContent)
+}
+
+// Drain stops new requests to the backend and waits for requests in flight.
+// With Wait it responds 200 once the backend is drained and can be removed,
+// 504 if the timeout expired and 409 if the backend was enabled meanwhile.
+// Without Wait it responds 202 right away, the outcome is logged and
+// connections are visible in List.
+func (h *AdminHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	var req DrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpcommon.JSONError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	pool, bk, err := h.backend(req.Pool, req.Addr)
+	if err != nil {
+		httpcommon.JSONError(w, http.StatusNotFound, err)
+		return
+	}
+
+	timeout := h.drainTimeout()
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Millisecond
+	}
+
+	log := h.log.ChildWithName("pool", pool)
+
+	if !req.Wait {
+		// set before responding, Drain sets it again
+		bk.SetAdminState(balancer.AdminDraining)
+		go func() {
+			_ = balancer.Drain(h.baseCtx, log, bk, timeout)
+		}()
+
+		httpcommon.JSONResponse(w, http.StatusAccepted, newBackendInfo(pool, bk))
+		return
+	}
+
+	switch err := balancer.Drain(r.Context(), log, bk, timeout); {
+	case err == nil:
+		httpcommon.JSONResponse(w, http.StatusOK, newBackendInfo(pool, bk))
+	case errors.Is(err, balancer.ErrDrainTimeout):
+		httpcommon.JSONError(w, http.StatusGatewayTimeout, err)
+	case errors.Is(err, balancer.ErrDrainCanceled):
+		httpcommon.JSONError(w, http.StatusConflict, err)
+	default:
+		// client has gone, the backend stays draining
+		h.log.Warn().Err(err).Str("addr", bk.Addr).Msg("[Admin] drain request canceled")
+	}
+}
+
+// setState returns handler which sets admin state of the backend from the body.
+func (h *AdminHandler) setState(state balancer.AdminState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req BackendRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpcommon.JSONError(w, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+
+		pool, bk, err := h.backend(req.Pool, req.Addr)
+		if err != nil {
+			httpcommon.JSONError(w, http.StatusNotFound, err)
+			return
+		}
+
+		bk.SetAdminState(state)
+
+		h.log.Info().Str("pool", pool).Str("addr", req.Addr).Str("state", string(state)).Msg("[Admin] backend state changed")
+		httpcommon.JSONResponse(w, http.StatusOK, newBackendInfo(pool, bk))
+	}
+}
+
+func (h *AdminHandler) drainTimeout() time.Duration {
+	if h.cfg.DrainTimeout <= 0 {
+		return defaultDrainTimeout
+	}
+	return h.cfg.DrainTimeout.Duration()
+}
+
+// backend finds backend addr in the pool.
+func (h *AdminHandler) backend(poolName, addr string) (string, *balancer.Backend, error) {
+	pool, bal, err := h.pool(poolName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	backends := bal.Backends()
+	idx := slices.IndexFunc(backends, func(bk *balancer.Backend) bool {
+		return bk.Addr == addr
+	})
+	if idx < 0 {
+		return "", nil, balancer.ErrBackendNotFound
+	}
+
+	return pool, backends[idx], nil
+}
+
+func (h *AdminHandler) pool(name string) (string, balancer.Balancer, error) {
+	if name == "" {
+		name = balancer.DefaultPool
+	}
+
+	bal, ok := h.pools[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%w %q", ErrUnknownPool, name)
+	}
+	return name, bal, nil
+}
+
+func validateAddr(addr string) error {
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidAddr
+	}
+	return nil
+}
+
+func statusFromError(err error) int {
+	switch {
+	case errors.Is(err, balancer.ErrBackendExists):
+		return http.StatusConflict
+	case errors.Is(err, balancer.ErrBackendNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, balancer.ErrNotManageable):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
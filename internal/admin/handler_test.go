@@ -0,0 +1,225 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/admin"
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdmin(t *testing.T) (http.Handler, balancer.Balancer) {
+	t.Helper()
+
+	mux, _, bal := newAdminWithConfig(t, admin.Config{})
+	return mux, bal
+}
+
+func newAdminWithConfig(t *testing.T, cfg admin.Config) (http.Handler, *admin.AdminHandler, balancer.Balancer) {
+	t.Helper()
+
+	log := zlog.NewTestLogger()
+	bal, err := balancer.New(log, balancer.Config{
+		Type:     balancer.RoundRobin,
+		Backends: []string{"http://a:8080"},
+	})
+	require.NoError(t, err)
+	bal.SetAlive("http://a:8080", true)
+
+	h := admin.NewAdminHandler(log, map[string]balancer.Balancer{balancer.DefaultPool: bal}, cfg)
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	return mux, h, bal
+}
+
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func list(t *testing.T, h http.Handler) []admin.BackendInfo {
+	t.Helper()
+
+	w := do(h, http.MethodGet, "/admin/backends", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var backends []admin.BackendInfo
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&backends))
+	return backends
+}
+
+func TestAdminHandler_Token(t *testing.T) {
+	h, _, _ := newAdminWithConfig(t, admin.Config{Token: "secret"})
+
+	routes := []struct{ method, target string }{
+		{http.MethodGet, "/admin/backends"},
+		{http.MethodPost, "/admin/backends"},
+		{http.MethodDelete, "/admin/backends?addr=http://a:8080"},
+		{http.MethodPost, "/admin/backends/drain"},
+		{http.MethodPost, "/admin/backends/enable"},
+		{http.MethodPost, "/admin/backends/disable"},
+		{http.MethodGet, "/admin/limiter"},
+	}
+	for _, rt := range routes {
+		w := do(h, rt.method, rt.target, `{"addr":"http://a:8080"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s without token", rt.method, rt.target)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/backends", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestConfig_Addr(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:9090", admin.Config{Port: 9090}.Addr(), "admin API is local by default")
+	assert.Equal(t, "0.0.0.0:9090", admin.Config{Host: "0.0.0.0", Port: 9090}.Addr())
+}
+
+func TestAdminHandler_AddRemove(t *testing.T) {
+	h, bal := newAdmin(t)
+
+	w := do(h, http.MethodPost, "/admin/backends", `{"addr":"http://b:8080"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = do(h, http.MethodPost, "/admin/backends", `{"addr":"http://b:8080"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = do(h, http.MethodPost, "/admin/backends", `{"addr":"b:8080"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(h, http.MethodPost, "/admin/backends", `{"pool":"api","addr":"http://c:8080"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	backends := list(t, h)
+	require.Len(t, backends, 2)
+	assert.Equal(t, admin.BackendInfo{
+		Pool:  balancer.DefaultPool,
+		Addr:  "http://b:8080",
+		Alive: false,
+		State: balancer.AdminEnabled,
+	}, backends[1])
+	assert.Len(t, bal.Backends(), 2)
+
+	w = do(h, http.MethodDelete, "/admin/backends?addr=http://b:8080", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = do(h, http.MethodDelete, "/admin/backends?addr=http://b:8080", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.Len(t, list(t, h), 1)
+}
+
+func TestAdminHandler_States(t *testing.T) {
+	h, bal := newAdmin(t)
+	bk := bal.Backends()[0]
+
+	w := do(h, http.MethodPost, "/admin/backends/disable", `{"addr":"http://a:8080"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, balancer.AdminDisabled, bk.AdminState())
+	assert.False(t, bk.Available())
+
+	w = do(h, http.MethodPost, "/admin/backends/drain", `{"addr":"http://a:8080"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, balancer.AdminDraining, list(t, h)[0].State)
+	assert.False(t, bk.Available())
+
+	w = do(h, http.MethodPost, "/admin/backends/enable", `{"addr":"http://a:8080"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, bk.Available())
+
+	w = do(h, http.MethodPost, "/admin/backends/enable", `{"addr":"http://b:8080"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminHandler_DrainWait(t *testing.T) {
+	h, bal := newAdmin(t)
+
+	// request in flight
+	inflight, err := bal.Pick(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		inflight.Done(balancer.Result{StatusCode: http.StatusOK})
+	}()
+
+	start := time.Now()
+	w := do(h, http.MethodPost, "/admin/backends/drain", `{"addr":"http://a:8080","wait":true,"timeout":2000}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "drain waits for the request in flight")
+
+	var info admin.BackendInfo
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+	assert.Equal(t, balancer.AdminDraining, info.State)
+	assert.Zero(t, info.Connections)
+
+	// no new requests to draining backend
+	_, err = bal.Pick(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Error(t, err)
+}
+
+func TestAdminHandler_DrainTimeout(t *testing.T) {
+	h, bal := newAdmin(t)
+
+	inflight, err := bal.Pick(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	defer inflight.Done(balancer.Result{})
+
+	w := do(h, http.MethodPost, "/admin/backends/drain", `{"addr":"http://a:8080","wait":true,"timeout":100}`)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, 1, list(t, h)[0].Connections)
+}
+
+func TestAdminHandler_ApplyDrainFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drain")
+	_, h, bal := newAdminWithConfig(t, admin.Config{DrainFile: path})
+	bk := bal.Backends()[0]
+
+	require.NoError(t, os.WriteFile(path, []byte("# deploy\nhttp://a:8080\n"), 0o644))
+	require.NoError(t, h.ApplyDrainFile(context.Background()))
+	assert.Equal(t, balancer.AdminDraining, bk.AdminState())
+
+	// backend removed from the file is enabled again
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	require.NoError(t, h.ApplyDrainFile(context.Background()))
+	assert.Equal(t, balancer.AdminEnabled, bk.AdminState())
+}
+
+func TestAdminHandler_LimiterStats(t *testing.T) {
+	mux, h, _ := newAdminWithConfig(t, admin.Config{})
+
+	w := do(mux, http.MethodGet, "/admin/limiter", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 1, Rate: 1})
+	lim.Allow(context.Background(), "user1", 1)
+	h.SetLimiter(lim)
+
+	w = do(mux, http.MethodGet, "/admin/limiter", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var stats limiter.Stats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, limiter.Stats{Buckets: 1}, stats)
+}
@@ -0,0 +1,46 @@
+package admin
+
+import "github.com/0x0FACED/load-balancer/internal/balancer"
+
+// BackendInfo is the state of one backend returned by the admin API.
+type BackendInfo struct {
+	Pool    string                `json:"pool"`
+	Addr    string                `json:"addr"`
+	Alive   bool                  `json:"alive"`
+	State   balancer.AdminState   `json:"state"`
+	Ejected bool                  `json:"ejected"`
+	Circuit balancer.CircuitState `json:"circuit,omitempty"`
+	// Connections is the number of requests in flight.
+	Connections int `json:"connections"`
+}
+
+// BackendRequest selects a backend of the pool, empty pool means balancer.DefaultPool.
+type BackendRequest struct {
+	Pool string `json:"pool"`
+	Addr string `json:"addr"`
+	// Weight is used by weighted balancers when the backend is added.
+	Weight int `json:"weight"`
+}
+
+// DrainRequest selects a backend to drain.
+type DrainRequest struct {
+	Pool string `json:"pool"`
+	Addr string `json:"addr"`
+	// Timeout in ms overrides Config.DrainTimeout.
+	Timeout int `json:"timeout"`
+	// Wait makes the request respond once the backend is drained
+	// or the timeout expires, otherwise it responds right away.
+	Wait bool `json:"wait"`
+}
+
+func newBackendInfo(pool string, bk *balancer.Backend) BackendInfo {
+	return BackendInfo{
+		Pool:        pool,
+		Addr:        bk.Addr,
+		Alive:       bk.IsAlive(),
+		State:       bk.AdminState(),
+		Ejected:     bk.Ejected(),
+		Circuit:     bk.CircuitState(),
+		Connections: bk.Connections(),
+	}
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/0x0FACED/load-balancer/config"
@@ -13,16 +14,23 @@ import (
 )
 
 type App struct {
-	srv      *http.Server
+	srv *http.Server
+	// admin is the admin API server, nil if it is disabled
+	admin    *http.Server
 	limiter  limiter.RateLimitter
 	balancer balancer.Balancer
+	// pools are balancers by pool name, changed by Reload
+	pools map[string]balancer.Balancer
 
 	cfg config.AppConfig
 	log *zlog.ZerologLogger
+	// mu serializes reloads
+	mu sync.Mutex
 }
 
 func New(
 	srv *http.Server,
+	admin *http.Server,
 	limitter limiter.RateLimitter,
 	balancer balancer.Balancer,
 	log *zlog.ZerologLogger,
@@ -30,13 +38,23 @@ func New(
 ) *App {
 	return &App{
 		srv:      srv,
+		admin:    admin,
 		limiter:  limitter,
 		balancer: balancer,
+		pools:    pools(balancer),
 		log:      log,
 		cfg:      cfg,
 	}
 }
 
+// pools returns balancers by pool name, a single balancer is DefaultPool.
+func pools(bal balancer.Balancer) map[string]balancer.Balancer {
+	if router, ok := bal.(*balancer.Router); ok {
+		return router.Pools()
+	}
+	return map[string]balancer.Balancer{balancer.DefaultPool: bal}
+}
+
 func (a *App) Start(ctx context.Context) error {
 	errChan := make(chan error, 3)
 
@@ -47,8 +65,17 @@ func (a *App) Start(ctx context.Context) error {
 		}
 	}()
 
-	a.log.Info().Msg("Starting rate limiter refill job")
-	a.limiter.StartRefillJob(ctx)
+	if a.admin != nil {
+		go func() {
+			a.log.Info().Str("address", a.admin.Addr).Msg("Starting admin server")
+			if err := a.admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errChan <- err
+			}
+		}()
+	}
+
+	a.log.Info().Msg("Starting rate limiter eviction job")
+	a.limiter.StartEvictionJob(ctx)
 
 	a.log.Info().Msg("Starting health check job")
 	a.balancer.StartHealthCheckJob(ctx)
@@ -76,6 +103,15 @@ func (a *App) Shutdown() error {
 		a.log.Info().Msg("Application server stopped")
 	}
 
+	if a.admin != nil {
+		if err := a.admin.Shutdown(ctx); err != nil {
+			a.log.Error().Err(err).Msg("Failed to shutdown admin server")
+			retErr = multierr.Append(retErr, err)
+		} else {
+			a.log.Info().Msg("Admin server stopped")
+		}
+	}
+
 	if err := a.limiter.Stop(); err != nil {
 		a.log.Error().Err(err).Msg("Failed to stop rate limiter")
 		retErr = multierr.Append(retErr, err)
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/0x0FACED/load-balancer/config"
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/rs/zerolog"
+)
+
+// SetLogLevel sets the level of all loggers. Loggers are created
+// with the lowest level, so the global one is in effect.
+func SetLogLevel(level string) error {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	zerolog.SetGlobalLevel(lvl)
+	return nil
+}
+
+// Reload applies cfg to the running app. Backends, health checks,
+// rate limiter defaults and log level are changed in place, other
+// changes are logged and take effect after restart.
+//
+// Only the applied changes are kept as the running config: sections
+// requiring restart and failed changes keep their old values, so the
+// next reload compares with what is actually running and retries them.
+func (a *App) Reload(cfg *config.AppConfig) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	old := a.cfg
+	applied := old
+	applied.Pools = maps.Clone(old.Pools)
+
+	var errs []error
+
+	if cfg.Logger.Level != old.Logger.Level {
+		if err := SetLogLevel(cfg.Logger.Level); err != nil {
+			errs = append(errs, fmt.Errorf("logger.level: %w", err))
+		} else {
+			applied.Logger.Level = cfg.Logger.Level
+			a.log.Info().Str("from", old.Logger.Level).Str("to", cfg.Logger.Level).Msg("[Reload] log level changed")
+		}
+	}
+	if cfg.Logger.LogsDir != old.Logger.LogsDir {
+		a.restartRequired("logger.logs_dir")
+	}
+
+	oldPools, newPools := poolConfigs(&old), poolConfigs(cfg)
+	for name, poolCfg := range newPools {
+		oldCfg, ok := oldPools[name]
+		bal, running := a.pools[name]
+		if !ok || !running {
+			a.restartRequired(poolKey(name))
+			continue
+		}
+
+		poolApplied, err := a.reloadPool(name, bal, oldCfg, poolCfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", poolKey(name), err))
+		}
+		if name == balancer.DefaultPool {
+			applied.Balancer = poolApplied
+		} else {
+			applied.Pools[name] = poolApplied
+		}
+	}
+	for name := range oldPools {
+		if _, ok := newPools[name]; !ok {
+			a.restartRequired(poolKey(name))
+		}
+	}
+
+	if cfg.RateLimiter.Type != old.RateLimiter.Type {
+		a.restartRequired("rate_limiter.type")
+	}
+	// costs are kept by the middleware
+	if !reflect.DeepEqual(cfg.RateLimiter.Costs, old.RateLimiter.Costs) {
+		a.restartRequired("rate_limiter.costs")
+	}
+	if !reflect.DeepEqual(limiterDefaults(cfg.RateLimiter), limiterDefaults(old.RateLimiter)) {
+		a.limiter.UpdateConfig(cfg.RateLimiter)
+		applied.RateLimiter = cfg.RateLimiter
+		applied.RateLimiter.Type = old.RateLimiter.Type
+		applied.RateLimiter.Costs = old.RateLimiter.Costs
+		a.log.Info().Msg("[Reload] rate limiter defaults changed")
+	}
+
+	if !reflect.DeepEqual(cfg.Routes, old.Routes) {
+		a.restartRequired("routes")
+	}
+	if cfg.Server != old.Server {
+		a.restartRequired("server")
+	}
+	if cfg.Proxy != old.Proxy {
+		a.restartRequired("proxy")
+	}
+	if cfg.Admin != old.Admin {
+		a.restartRequired("admin")
+	}
+	if cfg.Database != old.Database {
+		a.restartRequired("database")
+	}
+	if cfg.Redis != old.Redis {
+		a.restartRequired("redis")
+	}
+
+	a.cfg = applied
+
+	return errors.Join(errs...)
+}
+
+// limiterDefaults returns the part of cfg applied by UpdateConfig,
+// the type and costs are used at start only.
+func limiterDefaults(cfg limiter.Config) limiter.Config {
+	cfg.Type = ""
+	cfg.Costs = nil
+	return cfg
+}
+
+// reloadPool adds and removes backends of the pool and replaces
+// its health check settings. It returns old with the applied changes.
+func (a *App) reloadPool(name string, bal balancer.Balancer, old, cfg balancer.Config) (balancer.Config, error) {
+	log := a.log.ChildWithName("pool", name)
+
+	m, ok := bal.(balancer.BackendManager)
+	if !ok {
+		return old, balancer.ErrNotManageable
+	}
+
+	applied := old
+	applied.Backends = slices.Clone(old.Backends)
+	applied.Weights = maps.Clone(old.Weights)
+
+	var errs []error
+
+	for _, addr := range old.Backends {
+		if slices.Contains(cfg.Backends, addr) {
+			continue
+		}
+		if err := m.RemoveBackend(addr); err != nil {
+			errs = append(errs, fmt.Errorf("remove %s: %w", addr, err))
+			continue
+		}
+		applied.Backends = slices.DeleteFunc(applied.Backends, func(s string) bool { return s == addr })
+		delete(applied.Weights, addr)
+		log.Info().Str("addr", addr).Msg("[Reload] backend removed")
+	}
+
+	for _, addr := range cfg.Backends {
+		if slices.Contains(old.Backends, addr) {
+			continue
+		}
+		if _, err := m.AddBackend(addr, cfg.Weight(addr)); err != nil {
+			errs = append(errs, fmt.Errorf("add %s: %w", addr, err))
+			continue
+		}
+		applied.Backends = append(applied.Backends, addr)
+		if w, ok := cfg.Weights[addr]; ok {
+			if applied.Weights == nil {
+				applied.Weights = make(map[string]int)
+			}
+			applied.Weights[addr] = w
+		}
+		log.Info().Str("addr", addr).Msg("[Reload] backend added")
+	}
+
+	if !reflect.DeepEqual(cfg.HealthCheck, old.HealthCheck) {
+		if err := m.SetHealthCheck(cfg.HealthCheck); err != nil {
+			errs = append(errs, fmt.Errorf("healthcheck: %w", err))
+		} else {
+			applied.HealthCheck = cfg.HealthCheck
+			log.Info().Msg("[Reload] health check changed")
+		}
+	}
+
+	// weights of the kept backends can't be changed in place
+	for _, addr := range cfg.Backends {
+		if slices.Contains(old.Backends, addr) && old.Weight(addr) != cfg.Weight(addr) {
+			a.restartRequired(poolKey(name) + ".weights")
+			break
+		}
+	}
+
+	// the rest of the balancer settings are used to build it
+	old.Backends, cfg.Backends = nil, nil
+	old.Weights, cfg.Weights = nil, nil
+	old.HealthCheck, cfg.HealthCheck = balancer.HealthCheckConfig{}, balancer.HealthCheckConfig{}
+	if !reflect.DeepEqual(old, cfg) {
+		a.restartRequired(poolKey(name))
+	}
+
+	return applied, errors.Join(errs...)
+}
+
+func (a *App) restartRequired(key string) {
+	a.log.Warn().Str("key", key).Msg("[Reload] change requires restart")
+}
+
+// poolKey returns the config key of the pool.
+func poolKey(name string) string {
+	if name == balancer.DefaultPool {
+		return "balancer"
+	}
+	return "pools." + name
+}
+
+// poolConfigs returns balancer configs by pool name the way main builds
+// them: without routes there is only the top level balancer, with routes
+// it is DefaultPool if it has backends.
+func poolConfigs(cfg *config.AppConfig) map[string]balancer.Config {
+	if len(cfg.Routes) == 0 {
+		return map[string]balancer.Config{balancer.DefaultPool: cfg.Balancer}
+	}
+
+	pools := make(map[string]balancer.Config, len(cfg.Pools)+1)
+	for name, pool := range cfg.Pools {
+		pools[name] = pool
+	}
+	if len(cfg.Balancer.Backends) > 0 {
+		pools[balancer.DefaultPool] = cfg.Balancer
+	}
+	return pools
+}
@@ -0,0 +1,138 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/0x0FACED/load-balancer/config"
+	"github.com/0x0FACED/load-balancer/internal/app"
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/zlog"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLimiter struct {
+	cfg limiter.Config
+}
+
+func (l *fakeLimiter) Allow(context.Context, string, int) limiter.Decision {
+	return limiter.Decision{Allowed: true}
+}
+
+func (l *fakeLimiter) Reset(string)                     {}
+func (l *fakeLimiter) StartEvictionJob(context.Context) {}
+func (l *fakeLimiter) Stats() limiter.Stats             { return limiter.Stats{} }
+func (l *fakeLimiter) UpdateConfig(cfg limiter.Config)  { l.cfg = cfg }
+func (l *fakeLimiter) Stop() error                      { return nil }
+
+// failingBalancer fails to add the backend failAdd
+// and records removed backends.
+type failingBalancer struct {
+	balancer.Balancer
+	failAdd string
+	removed []string
+}
+
+func (b *failingBalancer) AddBackend(addr string, weight int) (*balancer.Backend, error) {
+	if addr == b.failAdd {
+		return nil, errors.New("add failed")
+	}
+	return b.Balancer.(balancer.BackendManager).AddBackend(addr, weight)
+}
+
+func (b *failingBalancer) RemoveBackend(addr string) error {
+	b.removed = append(b.removed, addr)
+	return b.Balancer.(balancer.BackendManager).RemoveBackend(addr)
+}
+
+func (b *failingBalancer) SetHealthCheck(cfg balancer.HealthCheckConfig) error {
+	return b.Balancer.(balancer.BackendManager).SetHealthCheck(cfg)
+}
+
+func addrs(bal balancer.Balancer) []string {
+	var res []string
+	for _, bk := range bal.Backends() {
+		res = append(res, bk.Addr)
+	}
+	return res
+}
+
+func TestReload(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+
+	cfg := config.AppConfig{
+		Balancer: balancer.Config{
+			Type:        balancer.RoundRobin,
+			Backends:    []string{"http://a", "http://b"},
+			HealthCheck: balancer.HealthCheckConfig{Interval: 1000, Timeout: 500},
+		},
+		RateLimiter: limiter.Config{Capacity: 10, Rate: 1, RefillIntrerval: 1000},
+		Logger:      config.LoggerConfig{Level: "info"},
+	}
+
+	log := zlog.NewTestLogger()
+	bal, err := balancer.New(log, cfg.Balancer)
+	require.NoError(t, err)
+
+	lim := &fakeLimiter{cfg: cfg.RateLimiter}
+	a := app.New(&http.Server{}, nil, lim, bal, log, cfg)
+
+	next := cfg
+	next.Balancer.Backends = []string{"http://b", "http://c"}
+	next.Balancer.HealthCheck.Path = "/health"
+	next.RateLimiter.Capacity = 20
+	next.Logger.Level = "warn"
+
+	require.NoError(t, a.Reload(&next))
+
+	assert.ElementsMatch(t, []string{"http://b", "http://c"}, addrs(bal))
+	assert.Equal(t, 20, lim.cfg.Capacity)
+	assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+
+	// reload of the same config changes nothing
+	require.NoError(t, a.Reload(&next))
+	assert.ElementsMatch(t, []string{"http://b", "http://c"}, addrs(bal))
+}
+
+func TestReload_FailedChangesAreRetried(t *testing.T) {
+	cfg := config.AppConfig{
+		Balancer: balancer.Config{
+			Type:     balancer.RoundRobin,
+			Backends: []string{"http://a"},
+		},
+		RateLimiter: limiter.Config{Capacity: 10, Rate: 1},
+		Logger:      config.LoggerConfig{Level: "info"},
+	}
+
+	log := zlog.NewTestLogger()
+	rr, err := balancer.New(log, cfg.Balancer)
+	require.NoError(t, err)
+	bal := &failingBalancer{Balancer: rr, failAdd: "http://b"}
+
+	a := app.New(&http.Server{}, nil, &fakeLimiter{}, bal, log, cfg)
+
+	next := cfg
+	next.Balancer.Backends = []string{"http://a", "http://b"}
+	require.Error(t, a.Reload(&next))
+	assert.Equal(t, []string{"http://a"}, addrs(bal))
+
+	// the failed add is retried by the next reload of the same config
+	bal.failAdd = ""
+	require.NoError(t, a.Reload(&next))
+	assert.Equal(t, []string{"http://a", "http://b"}, addrs(bal))
+
+	// a backend which was never added is not removed
+	bal.failAdd = "http://c"
+	next.Balancer.Backends = []string{"http://a", "http://b", "http://c"}
+	require.Error(t, a.Reload(&next))
+
+	next.Balancer.Backends = []string{"http://a"}
+	require.NoError(t, a.Reload(&next))
+	assert.Equal(t, []string{"http://b"}, bal.removed)
+	assert.Equal(t, []string{"http://a"}, addrs(bal))
+}
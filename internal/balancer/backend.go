@@ -1,11 +1,44 @@
 package balancer
 
-import "sync"
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdminState is the state of the backend set by the operator,
+// independently of health checks.
+type AdminState string
+
+const (
+	// AdminEnabled backend gets requests while it is healthy.
+	AdminEnabled AdminState = "enabled"
+	// AdminDraining backend gets no new requests, requests in flight complete.
+	AdminDraining AdminState = "draining"
+	// AdminDisabled backend gets no requests.
+	AdminDisabled AdminState = "disabled"
+)
 
 type Backend struct {
 	Addr  string
 	Alive bool
 
+	// admin is the operator state, empty means AdminEnabled
+	admin AdminState
+
+	// ejectedUntil is set by outlier detection, the backend gets
+	// no new requests until this time
+	ejectedUntil time.Time
+	// circuit is set by CircuitBreaker, nil if it is disabled
+	circuit *circuit
+	// upSince is the time the backend became alive, zero while it is down
+	upSince time.Time
+	// connections is the number of requests in flight,
+	// counted from Pick until Handle.Done
+	connections atomic.Int64
+
 	mu sync.RWMutex
 }
 
@@ -18,31 +51,167 @@ func (b *Backend) IsAlive() bool {
 func (b *Backend) SetAlive(alive bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+
+	switch {
+	case alive && !b.Alive:
+		b.upSince = time.Now()
+	case !alive:
+		b.upSince = time.Time{}
+	}
 	b.Alive = alive
 }
 
-type BackendWithConnections struct {
-	*Backend
-	connections int
-	mu          sync.Mutex
+// UpSince returns the time the backend became alive, zero if it is down.
+func (b *Backend) UpSince() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.upSince
+}
+
+// Available reports whether the backend can receive new requests:
+// it is enabled, alive, not ejected and its circuit is not open.
+// Balancers use it to pick backends.
+func (b *Backend) Available() bool {
+	b.mu.RLock()
+	ok := b.Alive && (b.admin == "" || b.admin == AdminEnabled) && !time.Now().Before(b.ejectedUntil)
+	c := b.circuit
+	b.mu.RUnlock()
+
+	return ok && (c == nil || c.ready())
+}
+
+// AdminState returns the operator state of the backend.
+func (b *Backend) AdminState() AdminState {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.admin == "" {
+		return AdminEnabled
+	}
+	return b.admin
 }
 
-func (b *BackendWithConnections) Inc() {
+// SetAdminState sets the operator state of the backend.
+func (b *Backend) SetAdminState(state AdminState) {
 	b.mu.Lock()
-	b.connections++
-	b.mu.Unlock()
+	defer b.mu.Unlock()
+	b.admin = state
 }
 
-func (b *BackendWithConnections) Dec() {
+// Ejected reports whether the backend is ejected by outlier detection.
+func (b *Backend) Ejected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return time.Now().Before(b.ejectedUntil)
+}
+
+// Eject removes the backend from balancing until the given time.
+func (b *Backend) Eject(until time.Time) {
 	b.mu.Lock()
-	if b.connections > 0 {
-		b.connections--
+	defer b.mu.Unlock()
+	b.ejectedUntil = until
+}
+
+// CircuitState returns state of the backend circuit breaker,
+// empty if circuit breaker is disabled.
+func (b *Backend) CircuitState() CircuitState {
+	c := b.getCircuit()
+	if c == nil {
+		return ""
 	}
-	b.mu.Unlock()
+	return c.snapshot()
+}
+
+func (b *Backend) getCircuit() *circuit {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.circuit
 }
 
-func (b *BackendWithConnections) Connections() int {
+func (b *Backend) setCircuit(c *circuit) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	return b.connections
+	b.circuit = c
+}
+
+// BackendWithConnections is a backend of the balancers picking by
+// the number of in-flight requests, see Backend.Connections. The counter
+// itself is kept by Backend, so it is tracked for every balancer type.
+type BackendWithConnections struct {
+	*Backend
+}
+
+// Inc counts one more request in flight. The counter is atomic,
+// so it can be read and updated without locks on the hot path.
+func (b *Backend) Inc() {
+	b.connections.Add(1)
+}
+
+// Dec counts a finished request, the counter never goes below zero.
+func (b *Backend) Dec() {
+	for {
+		curr := b.connections.Load()
+		if curr <= 0 {
+			return
+		}
+		if b.connections.CompareAndSwap(curr, curr-1) {
+			return
+		}
+	}
+}
+
+// Connections returns the number of requests in flight.
+func (b *Backend) Connections() int {
+	return int(b.connections.Load())
+}
+
+// backendSet is an immutable snapshot of backends. Adding or removing
+// a backend creates a new set, so balancers can read the current one
+// from an atomic pointer without locks.
+type backendSet[T comparable] struct {
+	list   []T
+	byAddr map[string]T
+}
+
+func newBackendSet[T comparable](addrs []string, create func(addr string) T) *backendSet[T] {
+	s := &backendSet[T]{
+		list:   make([]T, len(addrs)),
+		byAddr: make(map[string]T, len(addrs)),
+	}
+	for i, addr := range addrs {
+		s.list[i] = create(addr)
+		s.byAddr[addr] = s.list[i]
+	}
+	return s
+}
+
+// with returns a copy of the set with bk added.
+func (s *backendSet[T]) with(addr string, bk T) *backendSet[T] {
+	byAddr := maps.Clone(s.byAddr)
+	byAddr[addr] = bk
+
+	return &backendSet[T]{
+		list:   append(slices.Clip(s.list), bk),
+		byAddr: byAddr,
+	}
+}
+
+// without returns a copy of the set with backend addr removed.
+func (s *backendSet[T]) without(addr string) *backendSet[T] {
+	bk := s.byAddr[addr]
+
+	byAddr := maps.Clone(s.byAddr)
+	delete(byAddr, addr)
+
+	list := make([]T, 0, len(s.list))
+	for _, b := range s.list {
+		if b != bk {
+			list = append(list, b)
+		}
+	}
+
+	return &backendSet[T]{
+		list:   list,
+		byAddr: byAddr,
+	}
 }
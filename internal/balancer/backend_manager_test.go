@@ -0,0 +1,138 @@
+package balancer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var balancerTypes = []balancer.BalancerType{
+	balancer.RoundRobin,
+	balancer.LeastConn,
+	balancer.WeightedRoundRobin,
+	balancer.ConsistentHash,
+	balancer.P2C,
+	balancer.PeakEWMA,
+}
+
+func TestBackendManager_AddRemove(t *testing.T) {
+	for _, typ := range balancerTypes {
+		t.Run(string(typ), func(t *testing.T) {
+			bal, err := balancer.New(zlog.NewTestLogger(), balancer.Config{
+				Type:     typ,
+				Backends: []string{"http://a"},
+				// wrapped balancers forward add and remove
+				CircuitBreaker: balancer.CircuitBreakerConfig{ErrorThreshold: 50},
+				Outlier:        balancer.OutlierConfig{ConsecutiveFailures: 5},
+			})
+			require.NoError(t, err)
+
+			m, ok := bal.(balancer.BackendManager)
+			require.True(t, ok)
+
+			bk, err := m.AddBackend("http://b", 0)
+			require.NoError(t, err)
+			assert.False(t, bk.IsAlive(), "added backend waits for health check")
+			assert.Equal(t, balancer.CircuitClosed, bk.CircuitState())
+
+			_, err = m.AddBackend("http://b", 0)
+			assert.ErrorIs(t, err, balancer.ErrBackendExists)
+
+			bal.SetAlive("http://b", true)
+			assert.Equal(t, "http://b", pick(t, bal, newRequest()))
+
+			require.NoError(t, m.RemoveBackend("http://b"))
+			assert.ErrorIs(t, m.RemoveBackend("http://b"), balancer.ErrBackendNotFound)
+			assert.Len(t, bal.Backends(), 1)
+
+			_, err = bal.Pick(newRequest())
+			assert.Error(t, err, "only dead backend is left")
+		})
+	}
+}
+
+func TestBackendManager_ConcurrentPick(t *testing.T) {
+	for _, typ := range balancerTypes {
+		t.Run(string(typ), func(t *testing.T) {
+			bal, err := balancer.New(zlog.NewTestLogger(), balancer.Config{
+				Type:     typ,
+				Backends: []string{"http://a", "http://b"},
+			})
+			require.NoError(t, err)
+			bal.SetAlive("http://a", true)
+			bal.SetAlive("http://b", true)
+
+			m := bal.(balancer.BackendManager)
+
+			var (
+				stop atomic.Bool
+				wg   sync.WaitGroup
+			)
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for !stop.Load() {
+						h, err := bal.Pick(newRequest())
+						if assert.NoError(t, err) {
+							h.Done(balancer.Result{StatusCode: http.StatusOK})
+						}
+					}
+				}()
+			}
+
+			// "http://a" stays, so there is always an alive backend
+			for range 100 {
+				_, err := m.AddBackend("http://c", 0)
+				require.NoError(t, err)
+				bal.SetAlive("http://c", true)
+				require.NoError(t, m.RemoveBackend("http://b"))
+				_, err = m.AddBackend("http://b", 0)
+				require.NoError(t, err)
+				bal.SetAlive("http://b", true)
+				require.NoError(t, m.RemoveBackend("http://c"))
+			}
+
+			stop.Store(true)
+			wg.Wait()
+		})
+	}
+}
+
+func TestBackendManager_HealthCheck(t *testing.T) {
+	var checks atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bal := balancer.NewRoundRobinBalancer(zlog.NewTestLogger(), balancer.Config{
+		HealthCheck: balancer.HealthCheckConfig{Interval: 10, Timeout: 100},
+	})
+	bal.StartHealthCheckJob(ctx)
+
+	// backend added after the job is started is checked too
+	bk, err := bal.AddBackend(srv.URL, 0)
+	require.NoError(t, err)
+	assert.Eventually(t, bk.IsAlive, time.Second, 10*time.Millisecond)
+
+	// and its check is stopped on remove
+	require.NoError(t, bal.RemoveBackend(srv.URL))
+	time.Sleep(30 * time.Millisecond)
+	stopped := checks.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, checks.Load())
+}
@@ -1,17 +1,147 @@
 package balancer
 
-import "context"
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
 
 type BalancerType string
 
 const (
-	RoundRobin BalancerType = "round_robin"
-	LeastConn  BalancerType = "least_conn"
+	RoundRobin         BalancerType = "round_robin"
+	LeastConn          BalancerType = "least_conn"
+	WeightedRoundRobin BalancerType = "weighted_round_robin"
+	ConsistentHash     BalancerType = "consistent_hash"
+	P2C                BalancerType = "p2c"
+	PeakEWMA           BalancerType = "peak_ewma"
 )
 
 type Balancer interface {
-	Next() (string, error)
-	Release(addr string)
+	// Pick selects backend for the request r. Caller must call Done
+	// on the returned handle when the request is finished.
+	Pick(r *http.Request) (*Handle, error)
+	// Backends returns all backends of the balancer, alive or not.
+	Backends() []*Backend
 	SetAlive(addr string, alive bool)
 	StartHealthCheckJob(ctx context.Context)
 }
+
+var (
+	ErrBackendExists   = errors.New("backend already exists")
+	ErrBackendNotFound = errors.New("backend not found")
+	ErrNotManageable   = errors.New("balancer doesn't support adding and removing backends")
+)
+
+// BackendManager is implemented by balancers which backends can be added
+// and removed at runtime, concurrently with Pick.
+type BackendManager interface {
+	// AddBackend adds backend with addr. Like backends from config it is
+	// not alive until the health check passes. Weight is used by weighted
+	// balancers, 0 means the weight from Config.Weights.
+	AddBackend(addr string, weight int) (*Backend, error)
+	// RemoveBackend removes backend and stops its health check.
+	// Requests in flight to it are not interrupted.
+	RemoveBackend(addr string) error
+	// SetHealthCheck replaces health check settings, checks of all
+	// backends are restarted with them.
+	SetHealthCheck(cfg HealthCheckConfig) error
+}
+
+// addBackend adds backend to bal if it is a BackendManager.
+func addBackend(bal Balancer, addr string, weight int) (*Backend, error) {
+	m, ok := bal.(BackendManager)
+	if !ok {
+		return nil, ErrNotManageable
+	}
+	return m.AddBackend(addr, weight)
+}
+
+// removeBackend removes backend from bal if it is a BackendManager.
+func removeBackend(bal Balancer, addr string) error {
+	m, ok := bal.(BackendManager)
+	if !ok {
+		return ErrNotManageable
+	}
+	return m.RemoveBackend(addr)
+}
+
+// setHealthCheck sets health check settings of bal if it is a BackendManager.
+func setHealthCheck(bal Balancer, cfg HealthCheckConfig) error {
+	m, ok := bal.(BackendManager)
+	if !ok {
+		return ErrNotManageable
+	}
+	return m.SetHealthCheck(cfg)
+}
+
+// Result is the outcome of the request proxied to a backend.
+type Result struct {
+	// StatusCode is the response status code, 0 if there was no response.
+	StatusCode int
+	// Err is the transport error (dial, timeout etc.), nil if the backend responded.
+	Err error
+	// Duration is the time from sending the request to receiving response headers.
+	Duration time.Duration
+}
+
+// Failed reports whether the backend failed to handle the request.
+func (r Result) Failed() bool {
+	return r.Err != nil || r.StatusCode >= 500
+}
+
+// Handle is a backend picked for one request. The request is counted
+// in the backend connections until Done is called.
+type Handle struct {
+	Backend *Backend
+	// Retry is the retry config of the pool or route the backend was picked for.
+	Retry RetryConfig
+
+	done func(Result)
+	// counted is set if the handle holds one of the backend connections,
+	// wrapped handles leave it to the inner one
+	counted bool
+	once    sync.Once
+}
+
+func newHandle(backend *Backend, done func(Result)) *Handle {
+	backend.Inc()
+	return &Handle{
+		Backend: backend,
+		done:    done,
+		counted: true,
+	}
+}
+
+// observe returns a handle for the same backend, whose Done
+// calls fn with the result before h.Done. It is used by balancer wrappers.
+func (h *Handle) observe(fn func(Result)) *Handle {
+	return &Handle{
+		Backend: h.Backend,
+		Retry:   h.Retry,
+		done: func(res Result) {
+			fn(res)
+			h.Done(res)
+		},
+	}
+}
+
+// Addr returns address of the picked backend.
+func (h *Handle) Addr() string {
+	return h.Backend.Addr
+}
+
+// Done reports the result of the request to the balancer.
+// Only the first call has effect.
+func (h *Handle) Done(res Result) {
+	h.once.Do(func() {
+		if h.counted {
+			h.Backend.Dec()
+		}
+		if h.done != nil {
+			h.done(res)
+		}
+	})
+}
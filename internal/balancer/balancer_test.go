@@ -0,0 +1,58 @@
+package balancer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pick picks backend for r and returns its address, failing the test on error.
+func pick(t *testing.T, b balancer.Balancer, r *http.Request) string {
+	t.Helper()
+
+	h, err := b.Pick(r)
+	require.NoError(t, err)
+	return h.Addr()
+}
+
+func newRequest() *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/", nil)
+}
+
+type legacyBalancer struct {
+	released []string
+	results  []balancer.Result
+}
+
+func (b *legacyBalancer) Next(_ context.Context) (string, error) {
+	return "a", nil
+}
+
+func (b *legacyBalancer) Release(addr string, res balancer.Result) {
+	b.released = append(b.released, addr)
+	b.results = append(b.results, res)
+}
+
+func (b *legacyBalancer) SetAlive(addr string, alive bool) {}
+
+func (b *legacyBalancer) StartHealthCheckJob(ctx context.Context) {}
+
+func TestFromLegacy(t *testing.T) {
+	legacy := &legacyBalancer{}
+	b := balancer.FromLegacy(legacy)
+
+	h, err := b.Pick(newRequest())
+	assert.NoError(t, err)
+	assert.Equal(t, "a", h.Addr())
+
+	h.Done(balancer.Result{StatusCode: http.StatusOK})
+	h.Done(balancer.Result{StatusCode: http.StatusBadGateway}) // ignored
+
+	assert.Equal(t, []string{"a"}, legacy.released)
+	assert.Equal(t, []balancer.Result{{StatusCode: http.StatusOK}}, legacy.results)
+}
@@ -0,0 +1,285 @@
+package balancer
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0x0FACED/zlog"
+)
+
+const (
+	defaultCircuitMinRequests      = 20
+	defaultCircuitWindow           = 10 * time.Second
+	defaultCircuitCooldown         = 30 * time.Second
+	defaultCircuitHalfOpenRequests = 1
+
+	// circuitBuckets is the number of buckets in the rolling window
+	circuitBuckets = 10
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// circuitBucket counts requests of one slice of the rolling window.
+type circuitBucket struct {
+	// epoch is the number of the slice since unix epoch
+	epoch    int64
+	total    int
+	failures int
+}
+
+// circuit is the circuit breaker state of one backend.
+//
+// closed: requests pass, results are counted in the rolling window.
+// When there are at least minRequests in the window and the failure
+// percent reaches threshold, the circuit opens.
+//
+// open: the backend gets no requests. After cooldown the circuit
+// becomes half-open.
+//
+// half-open: up to halfOpenRequests trial requests pass. If all of them
+// succeed the circuit closes, any failure opens it again.
+type circuit struct {
+	addr string
+
+	threshold        int
+	minRequests      int
+	bucketSize       time.Duration
+	cooldown         time.Duration
+	halfOpenRequests int
+
+	state    CircuitState
+	openedAt time.Time
+	buckets  [circuitBuckets]circuitBucket
+	// trials is the number of in-flight trial requests in half-open state
+	trials int
+	// successes is the number of successful trial requests in half-open state
+	successes int
+
+	log *zlog.ZerologLogger
+	mu  sync.Mutex
+}
+
+// ready reports whether the circuit lets a new request pass.
+func (c *circuit) ready() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.currentState() {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		return c.trials < c.halfOpenRequests
+	default:
+		return true
+	}
+}
+
+// acquire is called when the backend is picked. Reports whether
+// the request is a trial request of the half-open circuit.
+//
+// Backend.Available and acquire are not atomic, so several concurrent picks
+// can slightly exceed halfOpenRequests. It is fine for trial requests.
+func (c *circuit) acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.currentState() != CircuitHalfOpen {
+		return false
+	}
+
+	c.trials++
+	return true
+}
+
+// record adds the result of the request to the circuit.
+func (c *circuit) record(failed, trial bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.currentState() {
+	case CircuitClosed:
+		now := time.Now()
+		epoch := now.UnixNano() / int64(c.bucketSize)
+
+		b := &c.buckets[epoch%circuitBuckets]
+		if b.epoch != epoch {
+			*b = circuitBucket{epoch: epoch}
+		}
+
+		b.total++
+		if failed {
+			b.failures++
+		}
+
+		total, failures := c.windowCounts(epoch)
+		if total >= c.minRequests && failures*100 >= total*c.threshold {
+			c.log.Warn().Str("addr", c.addr).Int("requests", total).Int("failures", failures).Msg("[CircuitBreaker] opened")
+			c.open(now)
+		}
+	case CircuitHalfOpen:
+		// result of the request started before the circuit became half-open
+		if !trial {
+			return
+		}
+
+		c.trials--
+		if failed {
+			c.log.Warn().Str("addr", c.addr).Msg("[CircuitBreaker] trial request failed, opened again")
+			c.open(time.Now())
+			return
+		}
+
+		c.successes++
+		if c.successes >= c.halfOpenRequests {
+			c.log.Info().Str("addr", c.addr).Msg("[CircuitBreaker] closed")
+			c.state = CircuitClosed
+			c.buckets = [circuitBuckets]circuitBucket{}
+		}
+	}
+}
+
+// currentState moves open circuit to half-open after cooldown
+// and returns the state. c.mu must be held.
+func (c *circuit) currentState() CircuitState {
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= c.cooldown {
+		c.log.Info().Str("addr", c.addr).Msg("[CircuitBreaker] half-open")
+		c.state = CircuitHalfOpen
+		c.trials = 0
+		c.successes = 0
+	}
+	return c.state
+}
+
+func (c *circuit) open(now time.Time) {
+	c.state = CircuitOpen
+	c.openedAt = now
+}
+
+// windowCounts sums buckets of the rolling window ending with
+// the slice epoch. c.mu must be held.
+func (c *circuit) windowCounts(epoch int64) (total, failures int) {
+	for _, b := range c.buckets {
+		if epoch-b.epoch < circuitBuckets {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+// snapshot returns the state without side effects.
+func (c *circuit) snapshot() CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.currentState()
+}
+
+// CircuitBreaker wraps a balancer with a circuit breaker per backend.
+// Backends with open circuits are skipped by Backend.Available,
+// so all balancer types stop picking them.
+type CircuitBreaker struct {
+	Balancer
+
+	threshold        int
+	minRequests      int
+	window           time.Duration
+	cooldown         time.Duration
+	halfOpenRequests int
+
+	log *zlog.ZerologLogger
+}
+
+func NewCircuitBreaker(log *zlog.ZerologLogger, bal Balancer, cfg CircuitBreakerConfig) *CircuitBreaker {
+	window := cfg.Window.Duration()
+	if window <= 0 {
+		window = defaultCircuitWindow
+	}
+
+	cooldown := cfg.Cooldown.Duration()
+	if cooldown <= 0 {
+		cooldown = defaultCircuitCooldown
+	}
+
+	minRequests := cfg.MinRequests
+	if minRequests <= 0 {
+		minRequests = defaultCircuitMinRequests
+	}
+
+	halfOpenRequests := cfg.HalfOpenRequests
+	if halfOpenRequests <= 0 {
+		halfOpenRequests = defaultCircuitHalfOpenRequests
+	}
+
+	cb := &CircuitBreaker{
+		Balancer:         bal,
+		threshold:        cfg.ErrorThreshold,
+		minRequests:      minRequests,
+		window:           window,
+		cooldown:         cooldown,
+		halfOpenRequests: halfOpenRequests,
+		log:              log,
+	}
+
+	for _, bk := range bal.Backends() {
+		cb.attach(bk)
+	}
+
+	return cb
+}
+
+// attach sets a new closed circuit to bk.
+func (cb *CircuitBreaker) attach(bk *Backend) {
+	bk.setCircuit(&circuit{
+		addr:             bk.Addr,
+		threshold:        cb.threshold,
+		minRequests:      cb.minRequests,
+		bucketSize:       cb.window / circuitBuckets,
+		cooldown:         cb.cooldown,
+		halfOpenRequests: cb.halfOpenRequests,
+		state:            CircuitClosed,
+		log:              cb.log,
+	})
+}
+
+// AddBackend adds the backend with a closed circuit.
+func (cb *CircuitBreaker) AddBackend(addr string, weight int) (*Backend, error) {
+	bk, err := addBackend(cb.Balancer, addr, weight)
+	if err != nil {
+		return nil, err
+	}
+
+	cb.attach(bk)
+	return bk, nil
+}
+
+func (cb *CircuitBreaker) RemoveBackend(addr string) error {
+	return removeBackend(cb.Balancer, addr)
+}
+
+func (cb *CircuitBreaker) SetHealthCheck(cfg HealthCheckConfig) error {
+	return setHealthCheck(cb.Balancer, cfg)
+}
+
+func (cb *CircuitBreaker) Pick(r *http.Request) (*Handle, error) {
+	h, err := cb.Balancer.Pick(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := h.Backend.getCircuit()
+	if c == nil {
+		return h, nil
+	}
+
+	trial := c.acquire()
+	return h.observe(func(res Result) {
+		c.record(res.Failed(), trial)
+	}), nil
+}
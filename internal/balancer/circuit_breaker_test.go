@@ -0,0 +1,119 @@
+package balancer_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker_OpenHalfOpenClose(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b"},
+	}
+	lc := balancer.NewLeastConnectionsBalancer(log, cfg)
+	b := balancer.NewCircuitBreaker(log, lc, balancer.CircuitBreakerConfig{
+		ErrorThreshold:   50,
+		MinRequests:      4,
+		Window:           10000,
+		Cooldown:         100,
+		HalfOpenRequests: 2,
+	})
+
+	b.SetAlive("a", true)
+	a := b.Backends()[0]
+	assert.Equal(t, balancer.CircuitClosed, a.CircuitState())
+
+	// 2 of 4 requests failed
+	results := []balancer.Result{
+		{StatusCode: http.StatusOK},
+		{Err: errors.New("connection refused")},
+		{StatusCode: http.StatusOK},
+		{StatusCode: http.StatusInternalServerError},
+	}
+	for _, res := range results {
+		h, err := b.Pick(newRequest())
+		require.NoError(t, err)
+		h.Done(res)
+	}
+
+	assert.Equal(t, balancer.CircuitOpen, a.CircuitState())
+	assert.False(t, a.Available())
+
+	_, err := b.Pick(newRequest())
+	assert.ErrorIs(t, err, balancer.ErrNoBackends, "open circuit should be skipped")
+
+	assert.Eventually(t, a.Available, time.Second, 10*time.Millisecond)
+	assert.Equal(t, balancer.CircuitHalfOpen, a.CircuitState())
+
+	// only 2 trial requests pass
+	h1, err := b.Pick(newRequest())
+	require.NoError(t, err)
+	h2, err := b.Pick(newRequest())
+	require.NoError(t, err)
+	_, err = b.Pick(newRequest())
+	assert.ErrorIs(t, err, balancer.ErrNoBackends)
+
+	h1.Done(balancer.Result{StatusCode: http.StatusOK})
+	assert.Equal(t, balancer.CircuitHalfOpen, a.CircuitState())
+	h2.Done(balancer.Result{StatusCode: http.StatusOK})
+	assert.Equal(t, balancer.CircuitClosed, a.CircuitState())
+	assert.True(t, a.Available())
+}
+
+func TestCircuitBreaker_TrialFailureReopens(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a"},
+	}
+	rr := balancer.NewRoundRobinBalancer(log, cfg)
+	b := balancer.NewCircuitBreaker(log, rr, balancer.CircuitBreakerConfig{
+		ErrorThreshold: 100,
+		MinRequests:    1,
+		Cooldown:       50,
+	})
+
+	b.SetAlive("a", true)
+	a := b.Backends()[0]
+
+	h, err := b.Pick(newRequest())
+	require.NoError(t, err)
+	h.Done(balancer.Result{StatusCode: http.StatusBadGateway})
+	assert.Equal(t, balancer.CircuitOpen, a.CircuitState())
+
+	assert.Eventually(t, a.Available, time.Second, 5*time.Millisecond)
+
+	h, err = b.Pick(newRequest())
+	require.NoError(t, err)
+	h.Done(balancer.Result{StatusCode: http.StatusBadGateway})
+	assert.Equal(t, balancer.CircuitOpen, a.CircuitState())
+}
+
+func TestCircuitBreaker_BelowThreshold(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a"},
+	}
+	rr := balancer.NewRoundRobinBalancer(log, cfg)
+	b := balancer.NewCircuitBreaker(log, rr, balancer.CircuitBreakerConfig{
+		ErrorThreshold: 50,
+		MinRequests:    10,
+	})
+
+	b.SetAlive("a", true)
+
+	// too few requests to evaluate error rate
+	for range 9 {
+		h, err := b.Pick(newRequest())
+		require.NoError(t, err)
+		h.Done(balancer.Result{StatusCode: http.StatusServiceUnavailable})
+	}
+
+	assert.Equal(t, balancer.CircuitClosed, b.Backends()[0].CircuitState())
+}
@@ -1,13 +1,212 @@
 package balancer
 
+import (
+	"errors"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/duration"
+	"github.com/0x0FACED/load-balancer/internal/pkg/validate"
+)
+
 type Config struct {
-	// Type can be "round_robin", "least_conn". Change it in config.json
+	// Type can be "round_robin", "least_conn", "weighted_round_robin", "consistent_hash",
+	// "p2c", "peak_ewma". Change it in config.json
 	Type        BalancerType      `json:"type"`
 	HealthCheck HealthCheckConfig `json:"healthcheck"`
 	Backends    []string          `json:"backends"`
+	// Weights maps backend address to its weight for weighted_round_robin
+	// and consistent_hash. Backends without an entry get weight 1.
+	Weights map[string]int `json:"weights"`
+	// Replicas is the number of virtual nodes per backend on the consistent_hash ring.
+	Replicas int `json:"replicas"`
+	// EWMA configures peak_ewma balancer.
+	EWMA EWMAConfig `json:"ewma"`
+	// Outlier configures passive health checking by the results of proxied requests.
+	Outlier OutlierConfig `json:"outlier"`
+	// Retry configures retries of failed requests on other backends of the pool.
+	Retry RetryConfig `json:"retry"`
+	// CircuitBreaker configures circuit breaker per backend.
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+	// SlowStart ramps traffic to backends which have just become alive.
+	SlowStart SlowStartConfig `json:"slow_start"`
 }
 
 type HealthCheckConfig struct {
-	Interval int `json:"interval"`
-	Timeout  int `json:"timeout"`
+	// Interval between checks of a backend, "5s" by default.
+	Interval duration.Milliseconds `json:"interval"`
+	// Timeout of one check, "3s" by default.
+	Timeout duration.Milliseconds `json:"timeout"`
+	// Path is requested on every backend, "/ping" by default.
+	Path string `json:"path"`
+	// Method of the check request, GET by default.
+	Method string `json:"method"`
+	// ExpectedStatus lists accepted status codes and ranges like "200-299".
+	// Only 200 is accepted by default.
+	ExpectedStatus []string `json:"expected_status"`
+	// Body, if set, must be contained in the response body.
+	Body string `json:"body"`
+	// BodyRegex, if set, must match the response body.
+	BodyRegex string `json:"body_regex"`
+	// Rise is the number of consecutive successful checks
+	// to mark a backend alive, 1 by default.
+	Rise int `json:"rise"`
+	// Fall is the number of consecutive failed checks
+	// to mark a backend dead, 1 by default.
+	Fall int `json:"fall"`
+}
+
+type EWMAConfig struct {
+	// Decay is the time window over which old latency observations lose their weight.
+	Decay duration.Milliseconds `json:"decay"`
+	// Penalty is the latency observed for failed requests.
+	Penalty duration.Milliseconds `json:"penalty"`
+}
+
+type OutlierConfig struct {
+	// ConsecutiveFailures is the number of connect errors or 5xx responses
+	// in a row after which the backend is ejected. 0 disables outlier detection.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// BaseEjectionTime is multiplied by the number of ejections in a row, "30s" by default.
+	BaseEjectionTime duration.Milliseconds `json:"base_ejection_time"`
+	// MaxEjectionTime caps the ejection time, "5m" by default.
+	MaxEjectionTime duration.Milliseconds `json:"max_ejection_time"`
+	// MaxEjectionPercent is the max percent of ejected backends, 50 by default.
+	// One backend can always be ejected.
+	MaxEjectionPercent int `json:"max_ejection_percent"`
+}
+
+type RetryConfig struct {
+	// MaxAttempts is the max number of attempts including the first one.
+	// 0 or 1 disables retries.
+	MaxAttempts int `json:"max_attempts"`
+	// RetryOnStatus lists response codes (e.g. 502, 503, 504) retried
+	// for idempotent methods. Connect errors are always retried.
+	RetryOnStatus []int `json:"retry_on_status"`
+	// BudgetPercent limits retries to this percent of requests, 20 by default.
+	BudgetPercent int `json:"budget_percent"`
+	// MaxBodySize is the max request body size (bytes) buffered to be replayed,
+	// 65536 by default. Requests with larger bodies are not retried.
+	MaxBodySize int64 `json:"max_body_size"`
+}
+
+type CircuitBreakerConfig struct {
+	// ErrorThreshold is the percent of failed requests in the window
+	// that opens the circuit. 0 disables circuit breaker.
+	ErrorThreshold int `json:"error_threshold"`
+	// MinRequests is the min number of requests in the window
+	// to evaluate the error rate, 20 by default.
+	MinRequests int `json:"min_requests"`
+	// Window is the rolling window of counted requests, "10s" by default.
+	Window duration.Milliseconds `json:"window"`
+	// Cooldown is the time the circuit stays open before it becomes
+	// half-open, "30s" by default.
+	Cooldown duration.Milliseconds `json:"cooldown"`
+	// HalfOpenRequests is the number of trial requests in half-open state
+	// that must succeed to close the circuit, 1 by default.
+	HalfOpenRequests int `json:"half_open_requests"`
+}
+
+type SlowStartConfig struct {
+	// Window is the time to ramp a recovered or newly added backend
+	// to its full weight. 0 disables slow start.
+	Window duration.Milliseconds `json:"window"`
+	// Aggression shapes the ramp: 1 is linear (default), greater values
+	// give more traffic at the beginning of the window.
+	Aggression float64 `json:"aggression"`
+	// MinWeightPercent is the share of the full weight at the start
+	// of the window, 10 by default.
+	MinWeightPercent int `json:"min_weight_percent"`
+}
+
+// Weight returns configured weight of the backend or 1 if it is not set.
+func (c Config) Weight(addr string) int {
+	if w, ok := c.Weights[addr]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// Validate checks all settings of the balancer. Every problem is
+// reported with the JSON path of the field.
+func (c Config) Validate() error {
+	var errs []error
+
+	switch c.Type {
+	case RoundRobin, LeastConn, WeightedRoundRobin, ConsistentHash, P2C, PeakEWMA:
+	default:
+		errs = append(errs, validate.Errorf("type", "unknown balancer type %q", c.Type))
+	}
+
+	seen := make(map[string]bool, len(c.Backends))
+	for i, addr := range c.Backends {
+		path := validate.Index("backends", i)
+		if seen[addr] {
+			errs = append(errs, validate.Errorf(path, "duplicate backend %q", addr))
+		}
+		seen[addr] = true
+
+		errs = append(errs, validate.URL(path, addr))
+	}
+
+	for addr, w := range c.Weights {
+		errs = append(errs, validate.NonNegative("weights."+addr, w))
+	}
+
+	errs = append(errs,
+		validate.NonNegative("replicas", c.Replicas),
+		validate.Field("healthcheck", c.HealthCheck.Validate()),
+		validate.Field("ewma", c.EWMA.Validate()),
+		validate.Field("outlier", c.Outlier.Validate()),
+		validate.Field("retry", c.Retry.Validate()),
+		validate.Field("circuit_breaker", c.CircuitBreaker.Validate()),
+		validate.Field("slow_start", c.SlowStart.Validate()),
+	)
+
+	return errors.Join(errs...)
+}
+
+func (c EWMAConfig) Validate() error {
+	return errors.Join(
+		validate.NonNegative("decay", c.Decay),
+		validate.NonNegative("penalty", c.Penalty),
+	)
+}
+
+func (c OutlierConfig) Validate() error {
+	return errors.Join(
+		validate.NonNegative("consecutive_failures", c.ConsecutiveFailures),
+		validate.NonNegative("base_ejection_time", c.BaseEjectionTime),
+		validate.NonNegative("max_ejection_time", c.MaxEjectionTime),
+		validate.Range("max_ejection_percent", c.MaxEjectionPercent, 0, 100),
+	)
+}
+
+func (c RetryConfig) Validate() error {
+	errs := []error{
+		validate.NonNegative("max_attempts", c.MaxAttempts),
+		validate.Range("budget_percent", c.BudgetPercent, 0, 100),
+		validate.NonNegative("max_body_size", c.MaxBodySize),
+	}
+	for i, status := range c.RetryOnStatus {
+		errs = append(errs, validate.Range(validate.Index("retry_on_status", i), status, 100, 599))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c CircuitBreakerConfig) Validate() error {
+	return errors.Join(
+		validate.Range("error_threshold", c.ErrorThreshold, 0, 100),
+		validate.NonNegative("min_requests", c.MinRequests),
+		validate.NonNegative("window", c.Window),
+		validate.NonNegative("cooldown", c.Cooldown),
+		validate.NonNegative("half_open_requests", c.HalfOpenRequests),
+	)
+}
+
+func (c SlowStartConfig) Validate() error {
+	return errors.Join(
+		validate.NonNegative("window", c.Window),
+		validate.NonNegative("aggression", c.Aggression),
+		validate.Range("min_weight_percent", c.MinWeightPercent, 0, 100),
+	)
 }
@@ -0,0 +1,249 @@
+package balancer
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/httpcommon"
+	"github.com/0x0FACED/zlog"
+)
+
+// defaultReplicas is the number of virtual nodes per backend (per unit of weight)
+// used when Config.Replicas is not set.
+const defaultReplicas = 100
+
+// vnode is a virtual node on the hash ring.
+type vnode struct {
+	hash    uint64
+	backend *Backend
+}
+
+// ConsistentHashBalancer maps client ID of the request (see httpcommon.ClientIDFromRequest)
+// onto a hash ring with virtual nodes, so requests of the same client stick
+// to the same backend.
+//
+// Dead backends stay on the ring and are skipped during lookup, so when
+// a backend goes down only its own keys move to the next backends on the ring
+// and come back once it is alive again. Keys of other backends are not remapped.
+// The same holds for backends added or removed at runtime.
+type ConsistentHashBalancer struct {
+	backends []*Backend
+	ring     []vnode
+
+	replicas int
+	weights  map[*Backend]int
+
+	slow   *slowStart
+	health *healthWatcher
+	log    *zlog.ZerologLogger
+
+	cfg Config
+	mu  sync.RWMutex
+}
+
+func NewConsistentHashBalancer(log *zlog.ZerologLogger, cfg Config) *ConsistentHashBalancer {
+	replicas := cfg.Replicas
+	if replicas <= 0 {
+		replicas = defaultReplicas
+	}
+
+	backendsList := make([]*Backend, len(cfg.Backends))
+	weights := make(map[*Backend]int, len(cfg.Backends))
+	for i, addr := range cfg.Backends {
+		backendsList[i] = &Backend{
+			Addr:  addr,
+			Alive: false,
+		}
+		weights[backendsList[i]] = cfg.Weight(addr)
+	}
+
+	b := &ConsistentHashBalancer{
+		backends: backendsList,
+		replicas: replicas,
+		weights:  weights,
+		slow:     newSlowStart(cfg.SlowStart),
+		cfg:      cfg,
+		health:   newHealthWatcher(NewHealthChecker(log, cfg.HealthCheck)),
+		log:      log,
+	}
+	b.buildRing()
+
+	return b
+}
+
+// buildRing places virtual nodes of all backends on the ring. Positions
+// depend only on the backend address, so rebuilding after adding or removing
+// a backend moves only the keys of that backend. b.mu must be held.
+func (b *ConsistentHashBalancer) buildRing() {
+	ring := make([]vnode, 0, len(b.backends)*b.replicas)
+	for _, backend := range b.backends {
+		for j := range b.replicas * b.weights[backend] {
+			ring = append(ring, vnode{
+				hash:    hashKey(backend.Addr + "#" + strconv.Itoa(j)),
+				backend: backend,
+			})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	b.ring = ring
+}
+
+func (b *ConsistentHashBalancer) Pick(r *http.Request) (*Handle, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.ring) == 0 {
+		return nil, ErrNoBackends
+	}
+
+	key := httpcommon.ClientIDFromRequest(r)
+	h := hashKey(key)
+
+	// first vnode clockwise from the key hash
+	start := sort.Search(len(b.ring), func(i int) bool {
+		return b.ring[i].hash >= h
+	})
+
+	// warming up backend passes part of its keys to the next backends
+	// on the ring, so a recovered backend gets its keys back gradually.
+	// Keys are admitted by their hash, so a key doesn't jump between
+	// backends during the window.
+	var fallback *Backend
+	for i := range b.ring {
+		node := b.ring[(start+i)%len(b.ring)]
+		if !usable(r, node.backend) {
+			continue
+		}
+
+		if b.slow.admitKey(node.backend, h) {
+			b.log.Debug().Str("addr", node.backend.Addr).Str("key", key).Msg("[ConsistentHash] is selected")
+			return newHandle(node.backend, nil), nil
+		}
+
+		if fallback == nil {
+			fallback = node.backend
+		}
+	}
+
+	if fallback != nil {
+		return newHandle(fallback, nil), nil
+	}
+
+	return nil, ErrNoBackends
+}
+
+func (b *ConsistentHashBalancer) Backends() []*Backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	backends := make([]*Backend, len(b.backends))
+	copy(backends, b.backends)
+	return backends
+}
+
+func (b *ConsistentHashBalancer) SetAlive(backend string, alive bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, bk := range b.backends {
+		if bk.Addr == backend {
+			bk.SetAlive(alive)
+			b.log.Debug().Str("addr", backend).Msg("[ConsistentHash] set alive")
+			return
+		}
+	}
+
+	b.log.Warn().Str("addr", backend).Msg("[ConsistentHash] set alive failed: backend not found")
+}
+
+// AddBackend adds backend with weight, if weight is 0 it is taken from Config.Weights.
+func (b *ConsistentHashBalancer) AddBackend(addr string, weight int) (*Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.indexOf(addr) >= 0 {
+		return nil, ErrBackendExists
+	}
+
+	if weight <= 0 {
+		weight = b.cfg.Weight(addr)
+	}
+
+	backend := &Backend{
+		Addr:  addr,
+		Alive: false,
+	}
+	b.backends = append(b.backends, backend)
+	b.weights[backend] = weight
+	b.buildRing()
+	b.health.add(backend)
+
+	b.log.Info().Str("addr", addr).Int("weight", weight).Msg("[ConsistentHash] backend added")
+	return backend, nil
+}
+
+func (b *ConsistentHashBalancer) RemoveBackend(addr string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.indexOf(addr)
+	if i < 0 {
+		return ErrBackendNotFound
+	}
+
+	backend := b.backends[i]
+	b.health.remove(backend)
+	b.backends = slices.Delete(b.backends, i, i+1)
+	delete(b.weights, backend)
+	b.buildRing()
+
+	b.log.Info().Str("addr", addr).Msg("[ConsistentHash] backend removed")
+	return nil
+}
+
+func (b *ConsistentHashBalancer) indexOf(addr string) int {
+	return slices.IndexFunc(b.backends, func(bk *Backend) bool {
+		return bk.Addr == addr
+	})
+}
+
+func (b *ConsistentHashBalancer) SetHealthCheck(cfg HealthCheckConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b.health.setChecker(NewHealthChecker(b.log, cfg))
+	return nil
+}
+
+func (b *ConsistentHashBalancer) StartHealthCheckJob(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.health.start(ctx, b.backends)
+}
+
+// hashKey hashes key with FNV-1a and mixes the result with the splitmix64
+// finalizer. Plain FNV spreads similar keys like "addr#1", "addr#2" poorly
+// over the ring.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,84 @@
+package balancer_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestFrom(clientID string) *http.Request {
+	r := newRequest()
+	r.Header.Set("X-Client-ID", clientID)
+	return r
+}
+
+func TestConsistentHashBalancer_Pick(t *testing.T) {
+	log := zlog.NewTestLogger()
+	backends := []string{"http://a:8081", "http://b:8082", "http://c:8083"}
+	cfg := balancer.Config{
+		Backends: backends,
+	}
+	b := balancer.NewConsistentHashBalancer(log, cfg)
+
+	_, err := b.Pick(requestFrom("user1"))
+	assert.ErrorIs(t, err, balancer.ErrNoBackends)
+
+	for _, addr := range backends {
+		b.SetAlive(addr, true)
+	}
+
+	counts := make(map[string]int)
+	for i := range 3000 {
+		r := requestFrom("user" + strconv.Itoa(i))
+
+		addr1 := pick(t, b, r)
+		addr2 := pick(t, b, r)
+
+		assert.Equal(t, addr1, addr2, "same key should go to the same backend")
+		counts[addr1]++
+	}
+
+	for _, addr := range backends {
+		assert.InDelta(t, 1000, counts[addr], 250, "keys should be spread evenly, got %v", counts)
+	}
+}
+
+func TestConsistentHashBalancer_MinimalRemapping(t *testing.T) {
+	log := zlog.NewTestLogger()
+	backends := []string{"http://a:8081", "http://b:8082", "http://c:8083"}
+	cfg := balancer.Config{
+		Backends: backends,
+	}
+	b := balancer.NewConsistentHashBalancer(log, cfg)
+
+	for _, addr := range backends {
+		b.SetAlive(addr, true)
+	}
+
+	before := make(map[string]string)
+	for i := range 1000 {
+		key := "user" + strconv.Itoa(i)
+		before[key] = pick(t, b, requestFrom(key))
+	}
+
+	b.SetAlive("http://b:8082", false)
+
+	for key, prev := range before {
+		addr := pick(t, b, requestFrom(key))
+		assert.NotEqual(t, "http://b:8082", addr)
+		if prev != "http://b:8082" {
+			assert.Equal(t, prev, addr, "key %s should not be remapped", key)
+		}
+	}
+
+	b.SetAlive("http://b:8082", true)
+
+	for key, prev := range before {
+		addr := pick(t, b, requestFrom(key))
+		assert.Equal(t, prev, addr, "key %s should return to its backend", key)
+	}
+}
@@ -0,0 +1,57 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0x0FACED/zlog"
+)
+
+// drainPollInterval is how often Drain checks the backend connections.
+const drainPollInterval = 50 * time.Millisecond
+
+var (
+	ErrDrainTimeout  = errors.New("drain timeout expired with requests in flight")
+	ErrDrainCanceled = errors.New("backend is not draining anymore")
+)
+
+// Drain puts bk into AdminDraining, so it gets no new requests, and waits
+// until its requests in flight complete. It returns nil once the backend has
+// no connections and can be removed, ErrDrainTimeout if they don't complete
+// within timeout and ErrDrainCanceled if the backend state is changed
+// meanwhile (e.g. it is enabled again). The outcome is logged.
+func Drain(ctx context.Context, log *zlog.ZerologLogger, bk *Backend, timeout time.Duration) error {
+	bk.SetAdminState(AdminDraining)
+
+	start := time.Now()
+	log.Info().Str("addr", bk.Addr).Int("connections", bk.Connections()).Dur("timeout", timeout).Msg("[Drain] started")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if bk.AdminState() != AdminDraining {
+			log.Info().Str("addr", bk.Addr).Msg("[Drain] canceled")
+			return ErrDrainCanceled
+		}
+
+		if bk.Connections() == 0 {
+			log.Info().Str("addr", bk.Addr).Dur("duration", time.Since(start)).Msg("[Drain] drained")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				log.Warn().Str("addr", bk.Addr).Int("connections", bk.Connections()).Msg("[Drain] timeout expired")
+				return ErrDrainTimeout
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package balancer_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandle_CountsConnections(t *testing.T) {
+	// connections are counted for every balancer type, not only least_conn
+	for _, typ := range balancerTypes {
+		t.Run(string(typ), func(t *testing.T) {
+			bal, err := balancer.New(zlog.NewTestLogger(), balancer.Config{
+				Type:           typ,
+				Backends:       []string{"http://a"},
+				CircuitBreaker: balancer.CircuitBreakerConfig{ErrorThreshold: 50},
+			})
+			require.NoError(t, err)
+			bal.SetAlive("http://a", true)
+
+			h1, err := bal.Pick(newRequest())
+			require.NoError(t, err)
+			h2, err := bal.Pick(newRequest())
+			require.NoError(t, err)
+
+			bk := bal.Backends()[0]
+			assert.Equal(t, 2, bk.Connections())
+
+			h1.Done(balancer.Result{StatusCode: http.StatusOK})
+			h1.Done(balancer.Result{StatusCode: http.StatusOK})
+			assert.Equal(t, 1, bk.Connections(), "repeated Done has no effect")
+
+			h2.Done(balancer.Result{StatusCode: http.StatusOK})
+			assert.Equal(t, 0, bk.Connections())
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	log := zlog.NewTestLogger()
+	bal := balancer.NewRoundRobinBalancer(log, balancer.Config{
+		Backends: []string{"http://a"},
+	})
+	bal.SetAlive("http://a", true)
+	bk := bal.Backends()[0]
+
+	h, err := bal.Pick(newRequest())
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- balancer.Drain(context.Background(), log, bk, time.Second)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return bk.AdminState() == balancer.AdminDraining
+	}, time.Second, time.Millisecond)
+	assert.False(t, bk.Available(), "draining backend gets no new requests")
+
+	h.Done(balancer.Result{StatusCode: http.StatusOK})
+	assert.NoError(t, <-done)
+}
+
+func TestDrain_TimeoutAndCancel(t *testing.T) {
+	log := zlog.NewTestLogger()
+	bal := balancer.NewRoundRobinBalancer(log, balancer.Config{
+		Backends: []string{"http://a"},
+	})
+	bal.SetAlive("http://a", true)
+	bk := bal.Backends()[0]
+
+	h, err := bal.Pick(newRequest())
+	require.NoError(t, err)
+	defer h.Done(balancer.Result{})
+
+	err = balancer.Drain(context.Background(), log, bk, 100*time.Millisecond)
+	assert.ErrorIs(t, err, balancer.ErrDrainTimeout)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		bk.SetAdminState(balancer.AdminEnabled)
+	}()
+
+	err = balancer.Drain(context.Background(), log, bk, time.Second)
+	assert.ErrorIs(t, err, balancer.ErrDrainCanceled)
+	assert.True(t, bk.Available())
+}
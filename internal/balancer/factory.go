@@ -0,0 +1,43 @@
+package balancer
+
+import (
+	"github.com/0x0FACED/zlog"
+)
+
+// New creates balancer of type cfg.Type wrapped with outlier detection,
+// circuit breaker and retry policy if they are enabled.
+func New(log *zlog.ZerologLogger, cfg Config) (Balancer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var bal Balancer
+	switch cfg.Type {
+	case RoundRobin:
+		bal = NewRoundRobinBalancer(log, cfg)
+	case LeastConn:
+		bal = NewLeastConnectionsBalancer(log, cfg)
+	case WeightedRoundRobin:
+		bal = NewWeightedRoundRobinBalancer(log, cfg)
+	case ConsistentHash:
+		bal = NewConsistentHashBalancer(log, cfg)
+	case P2C:
+		bal = NewP2CBalancer(log, cfg)
+	case PeakEWMA:
+		bal = NewPeakEWMABalancer(log, cfg)
+	}
+
+	if cfg.Outlier.ConsecutiveFailures > 0 {
+		bal = NewOutlierDetector(log, bal, cfg.Outlier)
+	}
+
+	if cfg.CircuitBreaker.ErrorThreshold > 0 {
+		bal = NewCircuitBreaker(log, bal, cfg.CircuitBreaker)
+	}
+
+	if cfg.Retry.MaxAttempts > 1 {
+		bal = &retryPolicy{Balancer: bal, cfg: cfg.Retry}
+	}
+
+	return bal, nil
+}
@@ -0,0 +1,314 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/validate"
+	"github.com/0x0FACED/zlog"
+)
+
+const (
+	defaultHealthCheckPath     = "/ping"
+	defaultHealthCheckMethod   = http.MethodGet
+	defaultHealthCheckInterval = 5000
+	defaultHealthCheckTimeout  = 3000
+
+	// maxHealthCheckBody limits how much of the response body is read
+	// to match Body and BodyRegex.
+	maxHealthCheckBody = 64 << 10
+)
+
+// statusRange is an inclusive range of accepted status codes.
+type statusRange struct {
+	from, to int
+}
+
+// parseStatusRanges parses codes like "200" and ranges like "200-299".
+func parseStatusRanges(specs []string) ([]statusRange, error) {
+	if len(specs) == 0 {
+		return []statusRange{{from: http.StatusOK, to: http.StatusOK}}, nil
+	}
+
+	ranges := make([]statusRange, 0, len(specs))
+	for _, spec := range specs {
+		fromStr, toStr, isRange := strings.Cut(strings.TrimSpace(spec), "-")
+		if !isRange {
+			toStr = fromStr
+		}
+
+		from, err := strconv.Atoi(strings.TrimSpace(fromStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q", spec)
+		}
+		to, err := strconv.Atoi(strings.TrimSpace(toStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q", spec)
+		}
+		if from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("invalid status range %q", spec)
+		}
+
+		ranges = append(ranges, statusRange{from: from, to: to})
+	}
+
+	return ranges, nil
+}
+
+// Validate checks that intervals are not negative and status ranges
+// and body regex can be parsed.
+func (c HealthCheckConfig) Validate() error {
+	errs := []error{
+		validate.NonNegative("interval", c.Interval),
+		validate.NonNegative("timeout", c.Timeout),
+		validate.NonNegative("rise", c.Rise),
+		validate.NonNegative("fall", c.Fall),
+	}
+
+	if _, err := parseStatusRanges(c.ExpectedStatus); err != nil {
+		errs = append(errs, validate.Field("expected_status", err))
+	}
+
+	if c.BodyRegex != "" {
+		if _, err := regexp.Compile(c.BodyRegex); err != nil {
+			errs = append(errs, validate.Field("body_regex", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// HealthChecker actively probes backends and flips their alive state
+// after Rise consecutive successful or Fall consecutive failed checks.
+// It is shared by all balancer types.
+type HealthChecker struct {
+	cfg HealthCheckConfig
+
+	client   *http.Client
+	statuses []statusRange
+	bodyRe   *regexp.Regexp
+
+	log *zlog.ZerologLogger
+}
+
+// NewHealthChecker creates health checker with defaults applied to the empty
+// fields of cfg. Invalid status ranges and body regex are logged and ignored,
+// use HealthCheckConfig.Validate to reject them beforehand.
+func NewHealthChecker(log *zlog.ZerologLogger, cfg HealthCheckConfig) *HealthChecker {
+	if cfg.Path == "" {
+		cfg.Path = defaultHealthCheckPath
+	}
+	if cfg.Method == "" {
+		cfg.Method = defaultHealthCheckMethod
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultHealthCheckInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHealthCheckTimeout
+	}
+	if cfg.Rise <= 0 {
+		cfg.Rise = 1
+	}
+	if cfg.Fall <= 0 {
+		cfg.Fall = 1
+	}
+
+	statuses, err := parseStatusRanges(cfg.ExpectedStatus)
+	if err != nil {
+		log.Error().Err(err).Msg("[HealthCheck] invalid expected status, using 200")
+		statuses, _ = parseStatusRanges(nil)
+	}
+
+	var bodyRe *regexp.Regexp
+	if cfg.BodyRegex != "" {
+		bodyRe, err = regexp.Compile(cfg.BodyRegex)
+		if err != nil {
+			log.Error().Err(err).Msg("[HealthCheck] invalid body regex, ignoring it")
+		}
+	}
+
+	return &HealthChecker{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: cfg.Timeout.Duration()},
+		statuses: statuses,
+		bodyRe:   bodyRe,
+		log:      log,
+	}
+}
+
+// Check probes the backend once and returns nil if it is healthy.
+func (hc *HealthChecker) Check(ctx context.Context, bk *Backend) error {
+	req, err := http.NewRequestWithContext(ctx, hc.cfg.Method, bk.Addr+hc.cfg.Path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !hc.statusExpected(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if hc.cfg.Body == "" && hc.bodyRe == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBody))
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+
+	if hc.cfg.Body != "" && !strings.Contains(string(body), hc.cfg.Body) {
+		return fmt.Errorf("body doesn't contain %q", hc.cfg.Body)
+	}
+
+	if hc.bodyRe != nil && !hc.bodyRe.Match(body) {
+		return fmt.Errorf("body doesn't match %q", hc.cfg.BodyRegex)
+	}
+
+	return nil
+}
+
+func (hc *HealthChecker) statusExpected(code int) bool {
+	for _, r := range hc.statuses {
+		if code >= r.from && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch checks bk every Interval until ctx is done. It blocks,
+// so it is meant to be run in its own goroutine.
+func (hc *HealthChecker) Watch(ctx context.Context, bk *Backend) {
+	ticker := time.NewTicker(hc.cfg.Interval.Duration())
+	defer ticker.Stop()
+
+	// consecutive successes and failures
+	var rise, fall int
+
+	for {
+		select {
+		case <-ctx.Done():
+			hc.log.Info().Str("addr", bk.Addr).Msg("[HealthCheck] stopped")
+			return
+		case <-ticker.C:
+			err := hc.Check(ctx, bk)
+			if err == nil {
+				rise++
+				fall = 0
+			} else {
+				fall++
+				rise = 0
+			}
+
+			alive := bk.IsAlive()
+			switch {
+			case err == nil && !alive && rise >= hc.cfg.Rise:
+				hc.log.Info().Str("addr", bk.Addr).Int("rise", rise).Msg("[HealthCheck] is UP")
+				bk.SetAlive(true)
+			case err != nil && alive && fall >= hc.cfg.Fall:
+				hc.log.Error().Str("addr", bk.Addr).Int("fall", fall).Err(err).Msg("[HealthCheck] is DOWN")
+				bk.SetAlive(false)
+			case err != nil:
+				hc.log.Debug().Str("addr", bk.Addr).Int("fall", fall).Err(err).Msg("[HealthCheck] failed")
+			default:
+				hc.log.Debug().Str("addr", bk.Addr).Int("rise", rise).Msg("[HealthCheck] passed")
+			}
+		}
+	}
+}
+
+// healthWatcher runs HealthChecker.Watch in a goroutine per backend.
+// Backends added after the job is started are watched right away,
+// watching of removed backends is stopped.
+type healthWatcher struct {
+	checker *HealthChecker
+
+	// ctx is the context of the health check job, nil until it is started
+	ctx     context.Context
+	cancels map[*Backend]context.CancelFunc
+	mu      sync.Mutex
+}
+
+func newHealthWatcher(checker *HealthChecker) *healthWatcher {
+	return &healthWatcher{
+		checker: checker,
+		cancels: make(map[*Backend]context.CancelFunc),
+	}
+}
+
+// start watches backends until ctx is done.
+func (w *healthWatcher) start(ctx context.Context, backends []*Backend) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.ctx = ctx
+	for _, bk := range backends {
+		w.watch(bk)
+	}
+}
+
+// add watches bk if the job is already started.
+func (w *healthWatcher) add(bk *Backend) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.ctx != nil {
+		w.watch(bk)
+	}
+}
+
+// remove stops watching bk.
+func (w *healthWatcher) remove(bk *Backend) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if cancel, ok := w.cancels[bk]; ok {
+		cancel()
+		delete(w.cancels, bk)
+	}
+}
+
+// setChecker replaces the checker and restarts running checks with it.
+func (w *healthWatcher) setChecker(checker *HealthChecker) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.checker = checker
+
+	backends := make([]*Backend, 0, len(w.cancels))
+	for bk, cancel := range w.cancels {
+		cancel()
+		delete(w.cancels, bk)
+		backends = append(backends, bk)
+	}
+
+	for _, bk := range backends {
+		w.watch(bk)
+	}
+}
+
+// watch starts the goroutine for bk. w.mu must be held.
+func (w *healthWatcher) watch(bk *Backend) {
+	if _, ok := w.cancels[bk]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(w.ctx)
+	w.cancels[bk] = cancel
+	go w.checker.Watch(ctx, bk)
+}
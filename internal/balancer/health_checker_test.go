@@ -0,0 +1,120 @@
+package balancer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthChecker_Check(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			if r.Method != http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/status":
+			_, _ = w.Write([]byte(`{"status":"ok","version":"1.2.3"}`))
+		case "/ping":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	bk := &balancer.Backend{Addr: srv.URL}
+
+	tests := []struct {
+		name    string
+		cfg     balancer.HealthCheckConfig
+		healthy bool
+	}{
+		{name: "defaults", cfg: balancer.HealthCheckConfig{}, healthy: true},
+		{name: "unexpected status", cfg: balancer.HealthCheckConfig{Path: "/missing"}, healthy: false},
+		{name: "status range", cfg: balancer.HealthCheckConfig{Path: "/healthz", Method: http.MethodHead, ExpectedStatus: []string{"200-299"}}, healthy: true},
+		{name: "status list", cfg: balancer.HealthCheckConfig{Path: "/missing", ExpectedStatus: []string{"200", "404"}}, healthy: true},
+		{name: "wrong method", cfg: balancer.HealthCheckConfig{Path: "/healthz", ExpectedStatus: []string{"200-299"}}, healthy: false},
+		{name: "body substring", cfg: balancer.HealthCheckConfig{Path: "/status", Body: `"status":"ok"`}, healthy: true},
+		{name: "body substring mismatch", cfg: balancer.HealthCheckConfig{Path: "/status", Body: `"status":"fail"`}, healthy: false},
+		{name: "body regex", cfg: balancer.HealthCheckConfig{Path: "/status", BodyRegex: `"version":"1\.\d+\.\d+"`}, healthy: true},
+		{name: "body regex mismatch", cfg: balancer.HealthCheckConfig{Path: "/status", BodyRegex: `"version":"2\.`}, healthy: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Timeout = 1000
+			hc := balancer.NewHealthChecker(zlog.NewTestLogger(), tt.cfg)
+
+			err := hc.Check(context.Background(), bk)
+			if tt.healthy {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestHealthChecker_RiseFall(t *testing.T) {
+	var healthy atomic.Bool
+	var checks atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks.Add(1)
+		if healthy.Load() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := balancer.HealthCheckConfig{
+		Interval: 50,
+		Timeout:  1000,
+		Rise:     3,
+		Fall:     2,
+	}
+	hc := balancer.NewHealthChecker(zlog.NewTestLogger(), cfg)
+	bk := &balancer.Backend{Addr: srv.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	healthy.Store(true)
+	go hc.Watch(ctx, bk)
+
+	// waitChecks waits until at least n more checks are done
+	waitChecks := func(n int64) {
+		start := checks.Load()
+		assert.Eventually(t, func() bool {
+			return checks.Load() >= start+n
+		}, time.Second, time.Millisecond)
+	}
+
+	assert.Eventually(t, bk.IsAlive, time.Second, time.Millisecond)
+	assert.GreaterOrEqual(t, checks.Load(), int64(3), "backend should be up only after rise checks")
+
+	healthy.Store(false)
+	waitChecks(1)
+	assert.True(t, bk.IsAlive(), "single failure should not mark backend down")
+
+	assert.Eventually(t, func() bool { return !bk.IsAlive() }, time.Second, time.Millisecond)
+}
+
+func TestHealthCheckConfig_Validate(t *testing.T) {
+	assert.NoError(t, balancer.HealthCheckConfig{}.Validate())
+	assert.NoError(t, balancer.HealthCheckConfig{ExpectedStatus: []string{"200", "300-399"}}.Validate())
+	assert.Error(t, balancer.HealthCheckConfig{ExpectedStatus: []string{"2xx"}}.Validate())
+	assert.Error(t, balancer.HealthCheckConfig{ExpectedStatus: []string{"299-200"}}.Validate())
+	assert.Error(t, balancer.HealthCheckConfig{BodyRegex: "("}.Validate())
+}
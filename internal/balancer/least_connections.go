@@ -3,14 +3,16 @@ package balancer
 import (
 	"context"
 	"net/http"
+	"slices"
 	"sync"
-	"time"
 
 	"github.com/0x0FACED/zlog"
 )
 
 type LeastConnectionsBalancer struct {
 	backends []*BackendWithConnections
+	slow     *slowStart
+	health   *healthWatcher
 	log      *zlog.ZerologLogger
 
 	cfg Config
@@ -25,55 +27,61 @@ func NewLeastConnectionsBalancer(log *zlog.ZerologLogger, cfg Config) *LeastConn
 				Addr:  addr,
 				Alive: false,
 			},
-			connections: 0,
 		}
 	}
 
 	return &LeastConnectionsBalancer{
 		backends: backendsWithConnections,
 		cfg:      cfg,
+		slow:     newSlowStart(cfg.SlowStart),
+		health:   newHealthWatcher(NewHealthChecker(log, cfg.HealthCheck)),
 		log:      log,
 	}
 }
 
-func (b *LeastConnectionsBalancer) Next() (string, error) {
+func (b *LeastConnectionsBalancer) Pick(r *http.Request) (*Handle, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	var selected *BackendWithConnections
+	var (
+		selected      *BackendWithConnections
+		selectedScore float64
+	)
 	for _, backend := range b.backends {
-		if !backend.IsAlive() {
+		if !usable(r, backend.Backend) {
 			continue
 		}
-		if selected == nil || backend.Connections() < selected.Connections() {
+
+		// warming up backend looks more loaded than it is
+		score := float64(backend.Connections()+1) / b.slow.factor(backend.Backend)
+		if selected == nil || score < selectedScore {
 			selected = backend
+			selectedScore = score
 		}
 	}
 
 	if selected == nil {
-		return "", ErrNoBackends
+		return nil, ErrNoBackends
 	}
 
-	selected.Inc()
+	h := newHandle(selected.Backend, func(Result) {
+		b.log.Debug().Str("addr", selected.Addr).Int("connections", selected.Connections()).Msg("[LeastConn] released")
+	})
 
 	b.log.Debug().Str("addr", selected.Addr).Int("connections", selected.Connections()).Msg("[LeastConn] is selected")
 
-	return selected.Addr, nil
+	return h, nil
 }
 
-func (b *LeastConnectionsBalancer) Release(addr string) {
+func (b *LeastConnectionsBalancer) Backends() []*Backend {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	for _, backend := range b.backends {
-		if backend.Addr == addr {
-			backend.Dec()
-			b.log.Debug().Str("addr", addr).Int("connections", backend.Connections()).Msg("[LeastConn] released")
-			return
-		}
+	backends := make([]*Backend, len(b.backends))
+	for i, backend := range b.backends {
+		backends[i] = backend.Backend
 	}
-
-	b.log.Warn().Str("addr", addr).Msg("[LeastConn] release failed: backend not found")
+	return backends
 }
 
 func (b *LeastConnectionsBalancer) SetAlive(backend string, alive bool) {
@@ -91,38 +99,65 @@ func (b *LeastConnectionsBalancer) SetAlive(backend string, alive bool) {
 	b.log.Warn().Str("addr", backend).Msg("[LeastConn] set alive failed: backend not found")
 }
 
+func (b *LeastConnectionsBalancer) AddBackend(addr string, _ int) (*Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.indexOf(addr) >= 0 {
+		return nil, ErrBackendExists
+	}
+
+	backend := &BackendWithConnections{
+		Backend: &Backend{
+			Addr:  addr,
+			Alive: false,
+		},
+	}
+	b.backends = append(b.backends, backend)
+	b.health.add(backend.Backend)
+
+	b.log.Info().Str("addr", addr).Msg("[LeastConn] backend added")
+	return backend.Backend, nil
+}
+
+func (b *LeastConnectionsBalancer) RemoveBackend(addr string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.indexOf(addr)
+	if i < 0 {
+		return ErrBackendNotFound
+	}
+
+	b.health.remove(b.backends[i].Backend)
+	b.backends = slices.Delete(b.backends, i, i+1)
+
+	b.log.Info().Str("addr", addr).Msg("[LeastConn] backend removed")
+	return nil
+}
+
+func (b *LeastConnectionsBalancer) indexOf(addr string) int {
+	return slices.IndexFunc(b.backends, func(bk *BackendWithConnections) bool {
+		return bk.Addr == addr
+	})
+}
+
+func (b *LeastConnectionsBalancer) SetHealthCheck(cfg HealthCheckConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b.health.setChecker(NewHealthChecker(b.log, cfg))
+	return nil
+}
+
 func (b *LeastConnectionsBalancer) StartHealthCheckJob(ctx context.Context) {
-	for _, backend := range b.backends {
-		go func(bk *BackendWithConnections) {
-			client := &http.Client{Timeout: time.Duration(b.cfg.HealthCheck.Timeout) * time.Millisecond}
-
-			ticker := time.NewTicker(time.Duration(b.cfg.HealthCheck.Interval) * time.Millisecond)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ctx.Done():
-					b.log.Info().Str("addr", bk.Addr).Msg("[HealthCheck] stopped")
-					return
-				case <-ticker.C:
-					url := bk.Addr + "/ping"
-					resp, err := client.Get(url)
-					if err != nil {
-						b.log.Error().Str("addr", bk.Addr).Err(err).Msg("[HealthCheck] is DOWN")
-						bk.SetAlive(false)
-						continue
-					}
-
-					_ = resp.Body.Close()
-					if resp.StatusCode == http.StatusOK {
-						b.log.Debug().Str("addr", bk.Addr).Int("connections", bk.connections).Int("code", resp.StatusCode).Msg("[HealthCheck] is UP")
-						bk.SetAlive(true)
-					} else {
-						b.log.Warn().Str("addr", bk.Addr).Int("code", resp.StatusCode).Msg("[HealthCheck] not ready")
-						bk.SetAlive(false)
-					}
-				}
-			}
-		}(backend)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backends := make([]*Backend, len(b.backends))
+	for i, backend := range b.backends {
+		backends[i] = backend.Backend
 	}
+	b.health.start(ctx, backends)
 }
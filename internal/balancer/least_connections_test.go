@@ -8,7 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestLeastConnectionsBalancer_Next(t *testing.T) {
+func TestLeastConnectionsBalancer_Pick(t *testing.T) {
 	log := zlog.NewTestLogger()
 	backends := []string{"a", "b"}
 	cfg := balancer.Config{
@@ -18,26 +18,27 @@ func TestLeastConnectionsBalancer_Next(t *testing.T) {
 
 	b.SetAlive("a", true)
 	b.SetAlive("b", true)
+
+	r := newRequest()
 	// a conns = 1
 	// b conns = 0
-	addr1, err := b.Next()
+	h1, err := b.Pick(r)
 	assert.NoError(t, err)
+	addr1 := h1.Addr()
 	assert.Contains(t, backends, addr1)
 	assert.Equal(t, "a", addr1)
 
 	// a conns = 1
 	// b conns = 1
-	addr2, err := b.Next()
-	assert.NoError(t, err)
+	addr2 := pick(t, b, r)
 	assert.Contains(t, backends, addr2)
 	assert.Equal(t, "b", addr2)
 
-	b.Release(addr1)
+	h1.Done(balancer.Result{})
 
 	// a conns = 0
 	// a conns = 1
-	addr3, err := b.Next()
-	assert.NoError(t, err)
+	addr3 := pick(t, b, r)
 	assert.Contains(t, backends, addr3)
 	assert.Equal(t, "a", addr3)
 }
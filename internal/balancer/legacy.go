@@ -0,0 +1,44 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+)
+
+// LegacyBalancer is the previous form of Balancer, which picks backends
+// by Next and releases them by address.
+type LegacyBalancer interface {
+	Next(ctx context.Context) (string, error)
+	Release(addr string, res Result)
+	SetAlive(addr string, alive bool)
+	StartHealthCheckJob(ctx context.Context)
+}
+
+// FromLegacy adapts LegacyBalancer to Balancer. Next is called with
+// the request context and Done of the handle calls Release.
+//
+// LegacyBalancer doesn't expose its backends, so Backend of the returned
+// handles is a detached copy with the picked address only.
+func FromLegacy(b LegacyBalancer) Balancer {
+	return &legacyAdapter{LegacyBalancer: b}
+}
+
+type legacyAdapter struct {
+	LegacyBalancer
+}
+
+// Backends returns nil, LegacyBalancer doesn't expose its backends.
+func (a *legacyAdapter) Backends() []*Backend {
+	return nil
+}
+
+func (a *legacyAdapter) Pick(r *http.Request) (*Handle, error) {
+	addr, err := a.Next(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	return newHandle(&Backend{Addr: addr, Alive: true}, func(res Result) {
+		a.Release(addr, res)
+	}), nil
+}
@@ -0,0 +1,193 @@
+package balancer
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0x0FACED/zlog"
+)
+
+const (
+	defaultBaseEjectionTime   = 30 * time.Second
+	defaultMaxEjectionTime    = 5 * time.Minute
+	defaultMaxEjectionPercent = 50
+)
+
+// outlierStats is the state of one backend in outlier detection.
+type outlierStats struct {
+	// failures is the number of consecutive failed requests
+	failures int
+	// ejections is the number of ejections in a row, the ejection time grows with it
+	ejections   int
+	lastEjected time.Time
+}
+
+// OutlierDetector is passive health checking: it wraps a balancer and watches
+// results of the real requests. A backend with ConsecutiveFailures connect
+// errors or 5xx responses in a row is ejected for BaseEjectionTime × number
+// of ejections in a row (capped by MaxEjectionTime) and then readmitted
+// automatically.
+//
+// No more than MaxEjectionPercent of backends are ejected at once
+// (but at least one can be), so a failure of the whole pool
+// doesn't leave it without backends at all.
+type OutlierDetector struct {
+	Balancer
+
+	consecutiveFailures int
+	baseEjectionTime    time.Duration
+	maxEjectionTime     time.Duration
+	maxEjectionPercent  int
+
+	stats map[*Backend]*outlierStats
+	mu    sync.Mutex
+
+	log *zlog.ZerologLogger
+}
+
+func NewOutlierDetector(log *zlog.ZerologLogger, bal Balancer, cfg OutlierConfig) *OutlierDetector {
+	base := cfg.BaseEjectionTime.Duration()
+	if base <= 0 {
+		base = defaultBaseEjectionTime
+	}
+
+	maxTime := cfg.MaxEjectionTime.Duration()
+	if maxTime <= 0 {
+		maxTime = defaultMaxEjectionTime
+	}
+
+	maxPercent := cfg.MaxEjectionPercent
+	if maxPercent <= 0 {
+		maxPercent = defaultMaxEjectionPercent
+	}
+
+	return &OutlierDetector{
+		Balancer:            bal,
+		consecutiveFailures: cfg.ConsecutiveFailures,
+		baseEjectionTime:    base,
+		maxEjectionTime:     max(maxTime, base),
+		maxEjectionPercent:  maxPercent,
+		stats:               make(map[*Backend]*outlierStats),
+		log:                 log,
+	}
+}
+
+func (d *OutlierDetector) Pick(r *http.Request) (*Handle, error) {
+	h, err := d.Balancer.Pick(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.observe(func(res Result) {
+		d.observe(h.Backend, res)
+	}), nil
+}
+
+func (d *OutlierDetector) AddBackend(addr string, weight int) (*Backend, error) {
+	return addBackend(d.Balancer, addr, weight)
+}
+
+// RemoveBackend removes the backend and forgets its ejection history.
+func (d *OutlierDetector) RemoveBackend(addr string) error {
+	if err := removeBackend(d.Balancer, addr); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for bk := range d.stats {
+		if bk.Addr == addr {
+			delete(d.stats, bk)
+		}
+	}
+
+	return nil
+}
+
+func (d *OutlierDetector) SetHealthCheck(cfg HealthCheckConfig) error {
+	return setHealthCheck(d.Balancer, cfg)
+}
+
+func (d *OutlierDetector) observe(bk *Backend, res Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	st, ok := d.stats[bk]
+	if !ok {
+		st = &outlierStats{}
+		d.stats[bk] = st
+	}
+
+	if !res.Failed() {
+		st.failures = 0
+		return
+	}
+
+	st.failures++
+	if st.failures < d.consecutiveFailures || bk.Ejected() {
+		return
+	}
+
+	if !d.canEject() {
+		d.log.Warn().Str("addr", bk.Addr).Int("failures", st.failures).Msg("[Outlier] ejection skipped: max ejection percent reached")
+		return
+	}
+
+	now := time.Now()
+	// backend has been fine long enough since the last ejection, start over
+	if now.Sub(st.lastEjected) > d.maxEjectionTime+d.ejectionTime(st.ejections) {
+		st.ejections = 0
+	}
+
+	st.ejections++
+	st.failures = 0
+	st.lastEjected = now
+
+	ejectFor := d.ejectionTime(st.ejections)
+	bk.Eject(now.Add(ejectFor))
+
+	d.log.Warn().
+		Str("addr", bk.Addr).
+		Int("ejections", st.ejections).
+		Dur("duration", ejectFor).
+		AnErr("last_error", res.Err).
+		Int("last_code", res.StatusCode).
+		Msg("[Outlier] ejected")
+
+	time.AfterFunc(ejectFor, func() {
+		d.mu.Lock()
+		// the backend is removed or ejected again meanwhile
+		current := d.stats[bk] == st && st.lastEjected.Equal(now)
+		d.mu.Unlock()
+
+		if current {
+			d.log.Info().Str("addr", bk.Addr).Msg("[Outlier] readmitted")
+		}
+	})
+}
+
+// ejectionTime returns duration of the n-th ejection in a row.
+func (d *OutlierDetector) ejectionTime(n int) time.Duration {
+	return min(d.baseEjectionTime*time.Duration(n), d.maxEjectionTime)
+}
+
+// canEject reports whether one more backend can be ejected
+// without exceeding maxEjectionPercent.
+func (d *OutlierDetector) canEject() bool {
+	backends := d.Backends()
+
+	var ejected int
+	for _, bk := range backends {
+		if bk.Ejected() {
+			ejected++
+		}
+	}
+
+	if ejected == 0 {
+		return true
+	}
+
+	return (ejected+1)*100 <= len(backends)*d.maxEjectionPercent
+}
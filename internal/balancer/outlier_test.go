@@ -0,0 +1,122 @@
+package balancer_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fail picks backends until addr is picked and finishes that request with res.
+func fail(t *testing.T, b balancer.Balancer, addr string, res balancer.Result) {
+	t.Helper()
+
+	for range 10 {
+		h, err := b.Pick(newRequest())
+		require.NoError(t, err)
+		if h.Addr() == addr {
+			h.Done(res)
+			return
+		}
+		h.Done(balancer.Result{StatusCode: http.StatusOK})
+	}
+
+	t.Fatalf("backend %s was not picked", addr)
+}
+
+func TestOutlierDetector_EjectAndReadmit(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b"},
+	}
+	rr := balancer.NewRoundRobinBalancer(log, cfg)
+	b := balancer.NewOutlierDetector(log, rr, balancer.OutlierConfig{
+		ConsecutiveFailures: 3,
+		BaseEjectionTime:    100,
+	})
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+
+	fail(t, b, "a", balancer.Result{Err: errors.New("connection refused")})
+	fail(t, b, "a", balancer.Result{StatusCode: http.StatusBadGateway})
+
+	// success resets consecutive failures
+	fail(t, b, "a", balancer.Result{StatusCode: http.StatusOK})
+	fail(t, b, "a", balancer.Result{StatusCode: http.StatusServiceUnavailable})
+	fail(t, b, "a", balancer.Result{StatusCode: http.StatusServiceUnavailable})
+	assert.True(t, rr.Backends()[0].Available())
+
+	fail(t, b, "a", balancer.Result{StatusCode: http.StatusServiceUnavailable})
+	assert.False(t, rr.Backends()[0].Available(), "a should be ejected")
+
+	r := newRequest()
+	for range 4 {
+		assert.Equal(t, "b", pick(t, b, r))
+	}
+
+	assert.Eventually(t, rr.Backends()[0].Available, time.Second, 10*time.Millisecond, "a should be readmitted")
+}
+
+func TestOutlierDetector_EjectionTimeGrows(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b"},
+	}
+	rr := balancer.NewRoundRobinBalancer(log, cfg)
+	b := balancer.NewOutlierDetector(log, rr, balancer.OutlierConfig{
+		ConsecutiveFailures: 1,
+		BaseEjectionTime:    50,
+		MaxEjectionTime:     1000,
+	})
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+	a := rr.Backends()[0]
+
+	fail(t, b, "a", balancer.Result{StatusCode: http.StatusInternalServerError})
+	start := time.Now()
+	assert.Eventually(t, a.Available, time.Second, time.Millisecond)
+	first := time.Since(start)
+
+	fail(t, b, "a", balancer.Result{StatusCode: http.StatusInternalServerError})
+	start = time.Now()
+	assert.Eventually(t, a.Available, time.Second, time.Millisecond)
+	second := time.Since(start)
+
+	assert.Greater(t, second, first+25*time.Millisecond, "second ejection should be longer")
+}
+
+func TestOutlierDetector_MaxEjectionPercent(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b", "c", "d"},
+	}
+	rr := balancer.NewRoundRobinBalancer(log, cfg)
+	b := balancer.NewOutlierDetector(log, rr, balancer.OutlierConfig{
+		ConsecutiveFailures: 1,
+		BaseEjectionTime:    10000,
+		MaxEjectionPercent:  50,
+	})
+
+	for _, addr := range []string{"a", "b", "c", "d"} {
+		b.SetAlive(addr, true)
+	}
+
+	for _, addr := range []string{"a", "b", "c"} {
+		fail(t, b, addr, balancer.Result{StatusCode: http.StatusInternalServerError})
+	}
+
+	var ejected int
+	for _, bk := range b.Backends() {
+		if bk.Ejected() {
+			ejected++
+		}
+	}
+	assert.Equal(t, 2, ejected, "no more than 50% of backends should be ejected")
+}
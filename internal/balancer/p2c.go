@@ -0,0 +1,183 @@
+package balancer
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/0x0FACED/zlog"
+)
+
+// p2cSamples is the number of random draws for a usable backend,
+// the backends are scanned after that.
+const p2cSamples = 8
+
+// P2CBalancer implements "power of two choices": it samples two random alive
+// backends and picks the one with fewer in-flight requests.
+//
+// It gives almost the same load distribution as LeastConnectionsBalancer,
+// but doesn't scan all backends and doesn't take balancer-wide locks on the
+// hot path: the backends set is an immutable snapshot replaced on add and
+// remove, and connection counters are atomic. Checking whether a sampled
+// backend is available takes only its own read lock (and the lock of its
+// circuit if the circuit breaker is enabled).
+type P2CBalancer struct {
+	backends atomic.Pointer[backendSet[*BackendWithConnections]]
+
+	slow   *slowStart
+	health *healthWatcher
+	log    *zlog.ZerologLogger
+
+	cfg Config
+	// mu serializes changes of the backends set
+	mu sync.Mutex
+}
+
+func NewP2CBalancer(log *zlog.ZerologLogger, cfg Config) *P2CBalancer {
+	b := &P2CBalancer{
+		cfg:    cfg,
+		slow:   newSlowStart(cfg.SlowStart),
+		health: newHealthWatcher(NewHealthChecker(log, cfg.HealthCheck)),
+		log:    log,
+	}
+	b.backends.Store(newBackendSet(cfg.Backends, newBackendWithConnections))
+
+	return b
+}
+
+func newBackendWithConnections(addr string) *BackendWithConnections {
+	return &BackendWithConnections{
+		Backend: &Backend{
+			Addr:  addr,
+			Alive: false,
+		},
+	}
+}
+
+func (b *P2CBalancer) Pick(r *http.Request) (*Handle, error) {
+	backends := b.backends.Load().list
+
+	first := randomAlive(r, backends, nil)
+	if first == nil {
+		return nil, ErrNoBackends
+	}
+
+	selected := first
+	if second := randomAlive(r, backends, first); second != nil && b.score(second) < b.score(first) {
+		selected = second
+	}
+
+	h := newHandle(selected.Backend, func(Result) {
+		b.log.Debug().Str("addr", selected.Addr).Int("connections", selected.Connections()).Msg("[P2C] released")
+	})
+
+	b.log.Debug().Str("addr", selected.Addr).Int("connections", selected.Connections()).Msg("[P2C] is selected")
+
+	return h, nil
+}
+
+// score is the load of the backend, warming up backend looks more loaded than it is.
+func (b *P2CBalancer) score(backend *BackendWithConnections) float64 {
+	return float64(backend.Connections()+1) / b.slow.factor(backend.Backend)
+}
+
+// randomAlive returns a random usable backend other than except, nil if
+// there is no such backend. Every usable backend is equally likely while
+// one of p2cSamples draws finds it. If many backends are down and the
+// draws fail, backends are scanned from a random position.
+func randomAlive(r *http.Request, backends []*BackendWithConnections, except *BackendWithConnections) *BackendWithConnections {
+	n := len(backends)
+	if n == 0 {
+		return nil
+	}
+
+	for range p2cSamples {
+		backend := backends[rand.IntN(n)]
+		if backend != except && usable(r, backend.Backend) {
+			return backend
+		}
+	}
+
+	start := rand.IntN(n)
+	for i := range n {
+		backend := backends[(start+i)%n]
+		if backend != except && usable(r, backend.Backend) {
+			return backend
+		}
+	}
+
+	return nil
+}
+
+func (b *P2CBalancer) Backends() []*Backend {
+	list := b.backends.Load().list
+
+	backends := make([]*Backend, len(list))
+	for i, backend := range list {
+		backends[i] = backend.Backend
+	}
+	return backends
+}
+
+func (b *P2CBalancer) SetAlive(backend string, alive bool) {
+	bk, ok := b.backends.Load().byAddr[backend]
+	if !ok {
+		b.log.Warn().Str("addr", backend).Msg("[P2C] set alive failed: backend not found")
+		return
+	}
+
+	bk.SetAlive(alive)
+	b.log.Debug().Str("addr", backend).Msg("[P2C] set alive")
+}
+
+func (b *P2CBalancer) AddBackend(addr string, _ int) (*Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	set := b.backends.Load()
+	if _, ok := set.byAddr[addr]; ok {
+		return nil, ErrBackendExists
+	}
+
+	backend := newBackendWithConnections(addr)
+	b.backends.Store(set.with(addr, backend))
+	b.health.add(backend.Backend)
+
+	b.log.Info().Str("addr", addr).Msg("[P2C] backend added")
+	return backend.Backend, nil
+}
+
+func (b *P2CBalancer) RemoveBackend(addr string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	set := b.backends.Load()
+	backend, ok := set.byAddr[addr]
+	if !ok {
+		return ErrBackendNotFound
+	}
+
+	b.backends.Store(set.without(addr))
+	b.health.remove(backend.Backend)
+
+	b.log.Info().Str("addr", addr).Msg("[P2C] backend removed")
+	return nil
+}
+
+func (b *P2CBalancer) SetHealthCheck(cfg HealthCheckConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b.health.setChecker(NewHealthChecker(b.log, cfg))
+	return nil
+}
+
+func (b *P2CBalancer) StartHealthCheckJob(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.health.start(ctx, b.Backends())
+}
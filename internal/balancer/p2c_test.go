@@ -0,0 +1,136 @@
+package balancer_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP2CBalancer_Pick(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b", "c"},
+	}
+	b := balancer.NewP2CBalancer(log, cfg)
+
+	r := newRequest()
+	_, err := b.Pick(r)
+	assert.ErrorIs(t, err, balancer.ErrNoBackends)
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+
+	// with two alive backends both are sampled every time,
+	// so the less loaded one is always picked
+	counts := make(map[string]int)
+	handles := make(map[string][]*balancer.Handle)
+	for range 10 {
+		h, err := b.Pick(r)
+		assert.NoError(t, err)
+		counts[h.Addr()]++
+		handles[h.Addr()] = append(handles[h.Addr()], h)
+	}
+
+	assert.Equal(t, 5, counts["a"])
+	assert.Equal(t, 5, counts["b"])
+	assert.Equal(t, 0, counts["c"])
+
+	// a conns = 2
+	// b conns = 5
+	for _, h := range handles["a"][:3] {
+		h.Done(balancer.Result{})
+	}
+
+	for range 3 {
+		assert.Equal(t, "a", pick(t, b, r))
+	}
+}
+
+func TestP2CBalancer_SingleAlive(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b", "c"},
+	}
+	b := balancer.NewP2CBalancer(log, cfg)
+
+	b.SetAlive("c", true)
+
+	r := newRequest()
+	for range 5 {
+		assert.Equal(t, "c", pick(t, b, r))
+	}
+}
+
+func TestP2CBalancer_UniformAfterDownBackends(t *testing.T) {
+	backends := benchmarkBackends(10)
+	b := balancer.NewP2CBalancer(zlog.NewTestLogger(), balancer.Config{Backends: backends})
+
+	// backends after a run of down ones are not picked more often
+	for _, addr := range backends[5:] {
+		b.SetAlive(addr, true)
+	}
+
+	r := newRequest()
+	counts := make(map[string]int)
+	for range 5000 {
+		h, err := b.Pick(r)
+		assert.NoError(t, err)
+		counts[h.Addr()]++
+		h.Done(balancer.Result{})
+	}
+
+	for _, addr := range backends[5:] {
+		assert.InDelta(t, 1000, counts[addr], 250, addr)
+	}
+}
+
+func benchmarkBalancer(b *testing.B, bal balancer.Balancer, backends []string) {
+	for _, addr := range backends {
+		bal.SetAlive(addr, true)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		r := newRequest()
+		for pb.Next() {
+			h, err := bal.Pick(r)
+			if err != nil {
+				b.Fatal(err)
+			}
+			h.Done(balancer.Result{})
+		}
+	})
+}
+
+func benchmarkBackends(n int) []string {
+	backends := make([]string, n)
+	for i := range backends {
+		backends[i] = "http://app:" + strconv.Itoa(8081+i)
+	}
+	return backends
+}
+
+func BenchmarkP2CBalancer_Parallel(b *testing.B) {
+	for _, n := range []int{4, 64, 512} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			backends := benchmarkBackends(n)
+			cfg := balancer.Config{Backends: backends}
+			benchmarkBalancer(b, balancer.NewP2CBalancer(zlog.NewTestLogger(), cfg), backends)
+		})
+	}
+}
+
+func BenchmarkLeastConnectionsBalancer_Parallel(b *testing.B) {
+	for _, n := range []int{4, 64, 512} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			backends := benchmarkBackends(n)
+			cfg := balancer.Config{Backends: backends}
+			benchmarkBalancer(b, balancer.NewLeastConnectionsBalancer(zlog.NewTestLogger(), cfg), backends)
+		})
+	}
+}
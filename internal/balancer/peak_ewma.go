@@ -0,0 +1,278 @@
+package balancer
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x0FACED/zlog"
+)
+
+const (
+	defaultEWMADecay   = 10 * time.Second
+	defaultEWMAPenalty = time.Second
+)
+
+// ewmaBackend is a backend with exponentially weighted moving average
+// of its response latency.
+type ewmaBackend struct {
+	*BackendWithConnections
+
+	// latency is EWMA of response latency in nanoseconds
+	latency float64
+	// observed is set after the first response of the backend
+	observed bool
+	stamp    time.Time
+	mu       sync.Mutex
+}
+
+// observe adds rtt to the moving average. Latency spikes are taken
+// immediately (peak), decreases are smoothed over the decay window,
+// so a backend that suddenly becomes slow stops receiving traffic right away.
+func (b *ewmaBackend) observe(rtt time.Duration, decay time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.stamp)
+	b.stamp = now
+
+	sample := float64(rtt)
+	if !b.observed || sample > b.latency {
+		b.observed = true
+		b.latency = sample
+		return
+	}
+
+	w := math.Exp(-float64(elapsed) / float64(decay))
+	b.latency = b.latency*w + sample*(1-w)
+}
+
+// observedLatency returns the latency EWMA, false if the backend
+// has not responded yet.
+func (b *ewmaBackend) observedLatency() (float64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.latency, b.observed
+}
+
+// cost returns the expected cost of sending one more request to the
+// backend. A backend without responses yet is assumed to have latency
+// unknown, so its requests in flight count as well.
+func (b *ewmaBackend) cost(unknown float64) float64 {
+	latency, ok := b.observedLatency()
+	if !ok {
+		latency = unknown
+	}
+
+	return latency * float64(b.Connections()+1)
+}
+
+// PeakEWMABalancer routes requests to the backend with the lowest
+// cost = latency EWMA × (in-flight requests + 1).
+//
+// Latency is fed by Handle.Done with the observed request duration.
+// Failed requests (transport error or 5xx) are observed as at least
+// the configured penalty, so a failing backend that answers fast doesn't
+// look like the best one.
+type PeakEWMABalancer struct {
+	// backends is an immutable snapshot replaced on add and remove
+	backends atomic.Pointer[backendSet[*ewmaBackend]]
+
+	decay   time.Duration
+	penalty time.Duration
+
+	slow   *slowStart
+	health *healthWatcher
+	log    *zlog.ZerologLogger
+
+	cfg Config
+	// mu serializes changes of the backends set
+	mu sync.Mutex
+}
+
+// NewPeakEWMABalancer creates the balancer, zero decay and penalty are defaults.
+func NewPeakEWMABalancer(log *zlog.ZerologLogger, cfg Config) *PeakEWMABalancer {
+	decay := cfg.EWMA.Decay.Duration()
+	if decay <= 0 {
+		decay = defaultEWMADecay
+	}
+
+	penalty := cfg.EWMA.Penalty.Duration()
+	if penalty <= 0 {
+		penalty = defaultEWMAPenalty
+	}
+
+	b := &PeakEWMABalancer{
+		decay:   decay,
+		penalty: penalty,
+		slow:    newSlowStart(cfg.SlowStart),
+		cfg:     cfg,
+		health:  newHealthWatcher(NewHealthChecker(log, cfg.HealthCheck)),
+		log:     log,
+	}
+	b.backends.Store(newBackendSet(cfg.Backends, newEWMABackend))
+
+	return b
+}
+
+func newEWMABackend(addr string) *ewmaBackend {
+	return &ewmaBackend{
+		BackendWithConnections: newBackendWithConnections(addr),
+		stamp:                  time.Now(),
+	}
+}
+
+func (b *PeakEWMABalancer) Pick(r *http.Request) (*Handle, error) {
+	selected, selectedCost := b.cheapest(r, true)
+	if selected == nil {
+		// all usable backends are warming up and skipped this time
+		selected, selectedCost = b.cheapest(r, false)
+	}
+
+	if selected == nil {
+		return nil, ErrNoBackends
+	}
+
+	h := newHandle(selected.Backend, func(res Result) {
+		b.release(selected, res)
+	})
+
+	b.log.Debug().Str("addr", selected.Addr).Float64("cost", selectedCost).Int("connections", selected.Connections()).Msg("[PeakEWMA] is selected")
+
+	return h, nil
+}
+
+// cheapest returns usable backend with the lowest cost. With slowStart
+// backends warming up are skipped with probability 1 - their slow start
+// factor.
+func (b *PeakEWMABalancer) cheapest(r *http.Request, slowStart bool) (*ewmaBackend, float64) {
+	backends := b.backends.Load().list
+	unknown := b.unknownLatency(backends)
+
+	var (
+		selected     *ewmaBackend
+		selectedCost float64
+	)
+	for _, backend := range backends {
+		if !usable(r, backend.Backend) || (slowStart && !b.slow.admit(backend.Backend)) {
+			continue
+		}
+
+		cost := backend.cost(unknown)
+		if selected == nil ||
+			cost < selectedCost ||
+			(cost == selectedCost && backend.Connections() < selected.Connections()) {
+			selected = backend
+			selectedCost = cost
+		}
+	}
+
+	return selected, selectedCost
+}
+
+// unknownLatency is the latency of backends without responses: the mean
+// of the observed ones, the penalty if there are none. With zero latency
+// a new backend would get every request until its first response.
+func (b *PeakEWMABalancer) unknownLatency(backends []*ewmaBackend) float64 {
+	var (
+		sum float64
+		n   int
+	)
+	for _, backend := range backends {
+		if latency, ok := backend.observedLatency(); ok {
+			sum += latency
+			n++
+		}
+	}
+
+	if n == 0 {
+		return float64(b.penalty)
+	}
+	return sum / float64(n)
+}
+
+func (b *PeakEWMABalancer) release(backend *ewmaBackend, res Result) {
+	rtt := res.Duration
+	if res.Failed() {
+		rtt = max(rtt, b.penalty)
+	}
+
+	backend.observe(rtt, b.decay)
+
+	b.log.Debug().Str("addr", backend.Addr).Dur("rtt", rtt).Int("connections", backend.Connections()).Msg("[PeakEWMA] released")
+}
+
+func (b *PeakEWMABalancer) Backends() []*Backend {
+	list := b.backends.Load().list
+
+	backends := make([]*Backend, len(list))
+	for i, backend := range list {
+		backends[i] = backend.Backend
+	}
+	return backends
+}
+
+func (b *PeakEWMABalancer) SetAlive(backend string, alive bool) {
+	bk, ok := b.backends.Load().byAddr[backend]
+	if !ok {
+		b.log.Warn().Str("addr", backend).Msg("[PeakEWMA] set alive failed: backend not found")
+		return
+	}
+
+	bk.SetAlive(alive)
+	b.log.Debug().Str("addr", backend).Msg("[PeakEWMA] set alive")
+}
+
+func (b *PeakEWMABalancer) AddBackend(addr string, _ int) (*Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	set := b.backends.Load()
+	if _, ok := set.byAddr[addr]; ok {
+		return nil, ErrBackendExists
+	}
+
+	backend := newEWMABackend(addr)
+	b.backends.Store(set.with(addr, backend))
+	b.health.add(backend.Backend)
+
+	b.log.Info().Str("addr", addr).Msg("[PeakEWMA] backend added")
+	return backend.Backend, nil
+}
+
+func (b *PeakEWMABalancer) RemoveBackend(addr string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	set := b.backends.Load()
+	backend, ok := set.byAddr[addr]
+	if !ok {
+		return ErrBackendNotFound
+	}
+
+	b.backends.Store(set.without(addr))
+	b.health.remove(backend.Backend)
+
+	b.log.Info().Str("addr", addr).Msg("[PeakEWMA] backend removed")
+	return nil
+}
+
+func (b *PeakEWMABalancer) SetHealthCheck(cfg HealthCheckConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b.health.setChecker(NewHealthChecker(b.log, cfg))
+	return nil
+}
+
+func (b *PeakEWMABalancer) StartHealthCheckJob(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.health.start(ctx, b.Backends())
+}
@@ -0,0 +1,93 @@
+package balancer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// observe sends one request to addr and releases it with result res.
+func observe(t *testing.T, b *balancer.PeakEWMABalancer, addr string, res balancer.Result) {
+	t.Helper()
+
+	b.SetAlive(addr, true)
+	h, err := b.Pick(newRequest())
+	assert.NoError(t, err)
+	assert.Equal(t, addr, h.Addr())
+	h.Done(res)
+	b.SetAlive(addr, false)
+}
+
+func TestPeakEWMABalancer_Pick(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b"},
+	}
+	b := balancer.NewPeakEWMABalancer(log, cfg)
+
+	r := newRequest()
+	_, err := b.Pick(r)
+	assert.ErrorIs(t, err, balancer.ErrNoBackends)
+
+	observe(t, b, "a", balancer.Result{StatusCode: 200, Duration: 100 * time.Millisecond})
+	observe(t, b, "b", balancer.Result{StatusCode: 200, Duration: 10 * time.Millisecond})
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+
+	// b cost = 10ms × (inflight + 1) stays below a cost = 100ms
+	// until b has 9 in-flight requests
+	for range 9 {
+		assert.Equal(t, "b", pick(t, b, r))
+	}
+
+	// equal cost, a has fewer in-flight requests
+	assert.Equal(t, "a", pick(t, b, r))
+}
+
+func TestPeakEWMABalancer_NewBackend(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b"},
+	}
+	b := balancer.NewPeakEWMABalancer(log, cfg)
+
+	// b has no responses yet, its requests in flight still count
+	observe(t, b, "a", balancer.Result{StatusCode: 200, Duration: 10 * time.Millisecond})
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+
+	r := newRequest()
+	counts := make(map[string]int)
+	for range 10 {
+		counts[pick(t, b, r)]++
+	}
+
+	assert.Equal(t, 5, counts["a"])
+	assert.Equal(t, 5, counts["b"], "new backend should not get every request")
+}
+
+func TestPeakEWMABalancer_FailurePenalty(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b"},
+		EWMA: balancer.EWMAConfig{
+			Penalty: 500,
+		},
+	}
+	b := balancer.NewPeakEWMABalancer(log, cfg)
+
+	// a fails fast, b is slow but healthy
+	observe(t, b, "a", balancer.Result{Err: errors.New("connection refused"), Duration: time.Millisecond})
+	observe(t, b, "b", balancer.Result{StatusCode: 200, Duration: 50 * time.Millisecond})
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+
+	assert.Equal(t, "b", pick(t, b, newRequest()))
+}
@@ -0,0 +1,55 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+type excludedCtxKey struct{}
+
+// WithExcluded returns a copy of ctx where backends with the given addresses
+// must not be picked, e.g. the ones that already failed this request.
+func WithExcluded(ctx context.Context, addrs ...string) context.Context {
+	prev, _ := ctx.Value(excludedCtxKey{}).([]string)
+	return context.WithValue(ctx, excludedCtxKey{}, append(slices.Clip(prev), addrs...))
+}
+
+// usable reports whether bk can be picked for r:
+// it is available and not excluded for this request.
+func usable(r *http.Request, bk *Backend) bool {
+	if !bk.Available() {
+		return false
+	}
+
+	excluded, _ := r.Context().Value(excludedCtxKey{}).([]string)
+	return !slices.Contains(excluded, bk.Addr)
+}
+
+// retryPolicy sets retry config of the pool to the picked handles.
+type retryPolicy struct {
+	Balancer
+	cfg RetryConfig
+}
+
+func (p *retryPolicy) AddBackend(addr string, weight int) (*Backend, error) {
+	return addBackend(p.Balancer, addr, weight)
+}
+
+func (p *retryPolicy) RemoveBackend(addr string) error {
+	return removeBackend(p.Balancer, addr)
+}
+
+func (p *retryPolicy) SetHealthCheck(cfg HealthCheckConfig) error {
+	return setHealthCheck(p.Balancer, cfg)
+}
+
+func (p *retryPolicy) Pick(r *http.Request) (*Handle, error) {
+	h, err := p.Balancer.Pick(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Retry = p.cfg
+	return h, nil
+}
@@ -0,0 +1,77 @@
+package balancer_test
+
+import (
+	"testing"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithExcluded(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b", "c"},
+	}
+
+	balancers := map[string]balancer.Balancer{
+		"round_robin":          balancer.NewRoundRobinBalancer(log, cfg),
+		"least_conn":           balancer.NewLeastConnectionsBalancer(log, cfg),
+		"weighted_round_robin": balancer.NewWeightedRoundRobinBalancer(log, cfg),
+		"consistent_hash":      balancer.NewConsistentHashBalancer(log, cfg),
+		"p2c":                  balancer.NewP2CBalancer(log, cfg),
+		"peak_ewma":            balancer.NewPeakEWMABalancer(log, cfg),
+	}
+
+	for name, b := range balancers {
+		t.Run(name, func(t *testing.T) {
+			for _, addr := range cfg.Backends {
+				b.SetAlive(addr, true)
+			}
+
+			r := newRequest()
+			first := pick(t, b, r)
+
+			r = r.WithContext(balancer.WithExcluded(r.Context(), first))
+			second := pick(t, b, r)
+			assert.NotEqual(t, first, second)
+
+			r = r.WithContext(balancer.WithExcluded(r.Context(), second))
+			third := pick(t, b, r)
+			assert.NotContains(t, []string{first, second}, third)
+
+			r = r.WithContext(balancer.WithExcluded(r.Context(), third))
+			_, err := b.Pick(r)
+			assert.Error(t, err, "all backends are excluded")
+		})
+	}
+}
+
+func TestRetryConfigFromPoolAndRoute(t *testing.T) {
+	log := zlog.NewTestLogger()
+	poolRetry := balancer.RetryConfig{MaxAttempts: 3, RetryOnStatus: []int{503}}
+	routeRetry := balancer.RetryConfig{MaxAttempts: 2}
+
+	routes := []balancer.RouteConfig{
+		{Match: balancer.MatchConfig{PathPrefix: "/api/"}, Pool: "api", Retry: &routeRetry},
+	}
+	pools := map[string]balancer.Config{
+		"api": {Type: balancer.RoundRobin, Backends: []string{"http://api"}, Retry: poolRetry},
+	}
+	fallback := balancer.Config{Type: balancer.RoundRobin, Backends: []string{"http://default"}, Retry: poolRetry}
+
+	router, err := balancer.NewRouter(log, routes, pools, fallback)
+	assert.NoError(t, err)
+	router.SetAlive("http://api", true)
+	router.SetAlive("http://default", true)
+
+	h, err := router.Pick(newRequest())
+	assert.NoError(t, err)
+	assert.Equal(t, poolRetry, h.Retry)
+
+	r := newRequest()
+	r.URL.Path = "/api/users"
+	h, err = router.Pick(r)
+	assert.NoError(t, err)
+	assert.Equal(t, routeRetry, h.Retry)
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"sync"
-	"time"
 
 	"github.com/0x0FACED/zlog"
 )
@@ -19,7 +19,9 @@ type RoundRobinBalancer struct {
 	backends []*Backend
 	current  int
 
-	log *zlog.ZerologLogger
+	slow   *slowStart
+	health *healthWatcher
+	log    *zlog.ZerologLogger
 
 	cfg Config
 	mu  sync.Mutex
@@ -38,45 +40,58 @@ func NewRoundRobinBalancer(log *zlog.ZerologLogger, cfg Config) *RoundRobinBalan
 		backends: backendsList,
 		current:  0,
 		cfg:      cfg,
+		slow:     newSlowStart(cfg.SlowStart),
+		health:   newHealthWatcher(NewHealthChecker(log, cfg.HealthCheck)),
 		log:      log,
 	}
 }
 
-func (b *RoundRobinBalancer) Next() (string, error) {
+func (b *RoundRobinBalancer) Pick(r *http.Request) (*Handle, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	if len(b.backends) == 0 {
 		b.log.Error().Msg("No backends available")
-		return "", ErrNoBackends
+		return nil, ErrNoBackends
 	}
 
-	var counter int
-	for range b.backends {
-		if counter > len(b.backends) {
-			return "", ErrNoBackends
-		}
-		if b.backends[b.current].IsAlive() {
-			break
-		}
-		b.current = (b.current + 1) % len(b.backends)
-		counter++
+	backend := b.next(r, true)
+	if backend == nil {
+		// all usable backends are warming up and skipped this time,
+		// slow start must not leave the pool without backends
+		backend = b.next(r, false)
+	}
+	if backend == nil {
+		return nil, ErrBackendNotAlive
 	}
-	backend := b.backends[b.current]
 
 	b.log.Debug().Str("addr", backend.Addr).Msg("[Next] is selected")
-	// double check
-	if !backend.IsAlive() {
-		return "", ErrBackendNotAlive
-	}
-	b.current = (b.current + 1) % len(b.backends)
 
-	return backend.Addr, nil
+	return newHandle(backend, nil), nil
 }
 
-// заглушка
-func (b *RoundRobinBalancer) Release(addr string) {
-	return
+// next returns the first usable backend from the current position and moves
+// the position past it. With slowStart backends warming up are skipped with
+// probability 1 - their slow start factor. b.mu must be held.
+func (b *RoundRobinBalancer) next(r *http.Request, slowStart bool) *Backend {
+	for range b.backends {
+		backend := b.backends[b.current]
+		b.current = (b.current + 1) % len(b.backends)
+
+		if usable(r, backend) && (!slowStart || b.slow.admit(backend)) {
+			return backend
+		}
+	}
+	return nil
+}
+
+func (b *RoundRobinBalancer) Backends() []*Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backends := make([]*Backend, len(b.backends))
+	copy(backends, b.backends)
+	return backends
 }
 
 func (b *RoundRobinBalancer) SetAlive(backend string, alive bool) {
@@ -94,38 +109,67 @@ func (b *RoundRobinBalancer) SetAlive(backend string, alive bool) {
 	b.log.Warn().Str("addr", backend).Msg("[RoundRobin] set alive failed: backend not found")
 }
 
-func (b *RoundRobinBalancer) StartHealthCheckJob(ctx context.Context) {
-	for _, backend := range b.backends {
-		go func(bk *Backend) {
-			client := &http.Client{Timeout: time.Duration(b.cfg.HealthCheck.Timeout) * time.Millisecond}
-
-			ticker := time.NewTicker(time.Duration(b.cfg.HealthCheck.Interval) * time.Millisecond)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ctx.Done():
-					b.log.Info().Str("addr", bk.Addr).Msg("[HealthCheck] stopped")
-					return
-				case <-ticker.C:
-					url := bk.Addr + "/ping"
-					resp, err := client.Get(url)
-					if err != nil {
-						b.log.Error().Str("addr", bk.Addr).Err(err).Msg("[HealthCheck] is DOWN")
-						bk.SetAlive(false)
-						continue
-					}
-
-					_ = resp.Body.Close()
-					if resp.StatusCode == http.StatusOK {
-						b.log.Debug().Str("addr", bk.Addr).Int("code", resp.StatusCode).Msg("[HealthCheck] is UP")
-						bk.SetAlive(true)
-					} else {
-						b.log.Warn().Str("addr", bk.Addr).Int("code", resp.StatusCode).Msg("[HealthCheck] not ready")
-						bk.SetAlive(false)
-					}
-				}
-			}
-		}(backend)
+func (b *RoundRobinBalancer) AddBackend(addr string, _ int) (*Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.indexOf(addr) >= 0 {
+		return nil, ErrBackendExists
+	}
+
+	backend := &Backend{
+		Addr:  addr,
+		Alive: false,
+	}
+	b.backends = append(b.backends, backend)
+	b.health.add(backend)
+
+	b.log.Info().Str("addr", addr).Msg("[RoundRobin] backend added")
+	return backend, nil
+}
+
+func (b *RoundRobinBalancer) RemoveBackend(addr string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.indexOf(addr)
+	if i < 0 {
+		return ErrBackendNotFound
 	}
+
+	b.health.remove(b.backends[i])
+	b.backends = slices.Delete(b.backends, i, i+1)
+
+	// keep the position pointing to the same next backend
+	if i < b.current {
+		b.current--
+	}
+	if b.current >= len(b.backends) {
+		b.current = 0
+	}
+
+	b.log.Info().Str("addr", addr).Msg("[RoundRobin] backend removed")
+	return nil
+}
+
+func (b *RoundRobinBalancer) indexOf(addr string) int {
+	return slices.IndexFunc(b.backends, func(bk *Backend) bool {
+		return bk.Addr == addr
+	})
+}
+
+func (b *RoundRobinBalancer) SetHealthCheck(cfg HealthCheckConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b.health.setChecker(NewHealthChecker(b.log, cfg))
+	return nil
+}
+
+func (b *RoundRobinBalancer) StartHealthCheckJob(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.health.start(ctx, b.backends)
 }
@@ -8,7 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestRoundRobinBalancer_Next(t *testing.T) {
+func TestRoundRobinBalancer_Pick(t *testing.T) {
 	log := zlog.NewTestLogger()
 	backends := []string{"a", "b", "c"}
 	cfg := balancer.Config{
@@ -20,10 +20,11 @@ func TestRoundRobinBalancer_Next(t *testing.T) {
 	b.SetAlive("b", true)
 	b.SetAlive("c", true)
 
-	addr1, _ := b.Next()
-	addr2, _ := b.Next()
-	addr3, _ := b.Next()
-	addr4, _ := b.Next()
+	r := newRequest()
+	addr1 := pick(t, b, r)
+	addr2 := pick(t, b, r)
+	addr3 := pick(t, b, r)
+	addr4 := pick(t, b, r)
 
 	assert.Equal(t, "a", addr1)
 	assert.Equal(t, "b", addr2)
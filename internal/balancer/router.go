@@ -0,0 +1,191 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/0x0FACED/zlog"
+)
+
+// DefaultPool is the name of the pool built from the top level balancer config.
+// It serves requests that don't match any route.
+const DefaultPool = "default"
+
+var ErrNoRoute = errors.New("no route for request")
+
+// RouteConfig sends requests matching Match to the backend pool Pool.
+type RouteConfig struct {
+	Match MatchConfig `json:"match"`
+	Pool  string      `json:"pool"`
+	// Retry overrides retry config of the pool for this route.
+	Retry *RetryConfig `json:"retry"`
+}
+
+// MatchConfig describes which requests match the route.
+// Empty fields match anything, all non-empty fields must match.
+type MatchConfig struct {
+	// Host is compared with request host without port, case-insensitive.
+	Host       string            `json:"host"`
+	PathPrefix string            `json:"path_prefix"`
+	Method     string            `json:"method"`
+	Headers    map[string]string `json:"headers"`
+}
+
+func (m MatchConfig) matches(r *http.Request) bool {
+	if m.Host != "" && !strings.EqualFold(m.Host, requestHost(r)) {
+		return false
+	}
+
+	if m.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, m.PathPrefix) {
+		return false
+	}
+
+	if m.Method != "" && !strings.EqualFold(m.Method, r.Method) {
+		return false
+	}
+
+	for name, value := range m.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return r.Host
+	}
+	return host
+}
+
+type route struct {
+	match MatchConfig
+	pool  string
+	retry *RetryConfig
+}
+
+// Router selects a named pool of backends by the first matching route
+// and delegates picking the backend to the balancer of that pool.
+// Every pool has its own balancer type and health check settings.
+type Router struct {
+	routes []route
+	pools  map[string]Balancer
+
+	log *zlog.ZerologLogger
+}
+
+// NewRouter creates balancer for every pool and the router over them.
+// If fallback has backends it becomes DefaultPool.
+func NewRouter(
+	log *zlog.ZerologLogger,
+	routes []RouteConfig,
+	pools map[string]Config,
+	fallback Config,
+) (*Router, error) {
+	balancers := make(map[string]Balancer, len(pools)+1)
+
+	if len(fallback.Backends) > 0 {
+		if _, ok := pools[DefaultPool]; ok {
+			return nil, fmt.Errorf("pool %q is reserved for the top level balancer config", DefaultPool)
+		}
+
+		bal, err := New(log.ChildWithName("pool", DefaultPool), fallback)
+		if err != nil {
+			return nil, fmt.Errorf("pool %q: %w", DefaultPool, err)
+		}
+		balancers[DefaultPool] = bal
+	}
+
+	for name, cfg := range pools {
+		bal, err := New(log.ChildWithName("pool", name), cfg)
+		if err != nil {
+			return nil, fmt.Errorf("pool %q: %w", name, err)
+		}
+		balancers[name] = bal
+	}
+
+	compiled := make([]route, len(routes))
+	for i, rt := range routes {
+		if _, ok := balancers[rt.Pool]; !ok {
+			return nil, fmt.Errorf("route %d: unknown pool %q", i, rt.Pool)
+		}
+		compiled[i] = route{
+			match: rt.Match,
+			pool:  rt.Pool,
+			retry: rt.Retry,
+		}
+	}
+
+	return &Router{
+		routes: compiled,
+		pools:  balancers,
+		log:    log,
+	}, nil
+}
+
+func (rt *Router) Pick(r *http.Request) (*Handle, error) {
+	var (
+		pool  = DefaultPool
+		retry *RetryConfig
+	)
+	for _, route := range rt.routes {
+		if route.match.matches(r) {
+			pool = route.pool
+			retry = route.retry
+			break
+		}
+	}
+
+	bal, ok := rt.pools[pool]
+	if !ok {
+		return nil, ErrNoRoute
+	}
+
+	rt.log.Debug().Str("pool", pool).Str("path", r.URL.Path).Msg("[Router] route matched")
+
+	h, err := bal.Pick(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if retry != nil {
+		h.Retry = *retry
+	}
+
+	return h, nil
+}
+
+// Pools returns balancers of the pools by name.
+func (rt *Router) Pools() map[string]Balancer {
+	return maps.Clone(rt.pools)
+}
+
+// Backends returns backends of all pools.
+func (rt *Router) Backends() []*Backend {
+	var backends []*Backend
+	for _, bal := range rt.pools {
+		backends = append(backends, bal.Backends()...)
+	}
+	return backends
+}
+
+// SetAlive sets alive state of the backend in every pool containing it.
+func (rt *Router) SetAlive(addr string, alive bool) {
+	for _, bal := range rt.pools {
+		bal.SetAlive(addr, alive)
+	}
+}
+
+func (rt *Router) StartHealthCheckJob(ctx context.Context) {
+	for _, bal := range rt.pools {
+		bal.StartHealthCheckJob(ctx)
+	}
+}
@@ -0,0 +1,101 @@
+package balancer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter(t *testing.T) *balancer.Router {
+	t.Helper()
+
+	log := zlog.NewTestLogger()
+	routes := []balancer.RouteConfig{
+		{Match: balancer.MatchConfig{Host: "admin.example"}, Pool: "admin"},
+		{Match: balancer.MatchConfig{PathPrefix: "/api/", Method: http.MethodPost}, Pool: "api-write"},
+		{Match: balancer.MatchConfig{PathPrefix: "/api/"}, Pool: "api"},
+		{Match: balancer.MatchConfig{PathPrefix: "/static/", Headers: map[string]string{"X-Canary": "1"}}, Pool: "canary"},
+		{Match: balancer.MatchConfig{PathPrefix: "/static/"}, Pool: "static"},
+	}
+	pools := map[string]balancer.Config{
+		"admin":     {Type: balancer.RoundRobin, Backends: []string{"http://admin"}},
+		"api-write": {Type: balancer.LeastConn, Backends: []string{"http://api-write"}},
+		"api":       {Type: balancer.LeastConn, Backends: []string{"http://api"}},
+		"canary":    {Type: balancer.RoundRobin, Backends: []string{"http://canary"}},
+		"static":    {Type: balancer.RoundRobin, Backends: []string{"http://static"}},
+	}
+	fallback := balancer.Config{Type: balancer.RoundRobin, Backends: []string{"http://default"}}
+
+	router, err := balancer.NewRouter(log, routes, pools, fallback)
+	assert.NoError(t, err)
+
+	for _, addr := range []string{"admin", "api-write", "api", "canary", "static", "default"} {
+		router.SetAlive("http://"+addr, true)
+	}
+
+	return router
+}
+
+func TestRouter_Pick(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		want    string
+	}{
+		{name: "host", method: http.MethodGet, target: "http://admin.example:8080/api/users", want: "http://admin"},
+		{name: "path and method", method: http.MethodPost, target: "/api/users", want: "http://api-write"},
+		{name: "path", method: http.MethodGet, target: "/api/users", want: "http://api"},
+		{name: "path and header", method: http.MethodGet, target: "/static/app.js", headers: map[string]string{"X-Canary": "1"}, want: "http://canary"},
+		{name: "header mismatch", method: http.MethodGet, target: "/static/app.js", headers: map[string]string{"X-Canary": "0"}, want: "http://static"},
+		{name: "no match", method: http.MethodGet, target: "/ping", want: "http://default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			assert.Equal(t, tt.want, pick(t, router, r))
+		})
+	}
+}
+
+func TestRouter_NoRoute(t *testing.T) {
+	log := zlog.NewTestLogger()
+	routes := []balancer.RouteConfig{
+		{Match: balancer.MatchConfig{PathPrefix: "/api/"}, Pool: "api"},
+	}
+	pools := map[string]balancer.Config{
+		"api": {Type: balancer.RoundRobin, Backends: []string{"http://api"}},
+	}
+
+	router, err := balancer.NewRouter(log, routes, pools, balancer.Config{})
+	assert.NoError(t, err)
+
+	_, err = router.Pick(httptest.NewRequest(http.MethodGet, "/ping", nil))
+	assert.ErrorIs(t, err, balancer.ErrNoRoute)
+}
+
+func TestNewRouter_InvalidConfig(t *testing.T) {
+	log := zlog.NewTestLogger()
+	pools := map[string]balancer.Config{
+		"api": {Type: balancer.RoundRobin, Backends: []string{"http://api"}},
+	}
+
+	_, err := balancer.NewRouter(log, []balancer.RouteConfig{{Pool: "unknown"}}, pools, balancer.Config{})
+	assert.Error(t, err, "route to unknown pool")
+
+	pools["broken"] = balancer.Config{Type: "random", Backends: []string{"http://x"}}
+	_, err = balancer.NewRouter(log, nil, pools, balancer.Config{})
+	assert.Error(t, err, "pool with unknown balancer type")
+}
@@ -0,0 +1,83 @@
+package balancer
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultSlowStartAggression = 1.0
+	defaultSlowStartMinPercent = 10
+)
+
+// slowStart ramps the share of traffic of a backend which has just become
+// alive (recovered or newly added) from MinWeightPercent to full
+// during Window, so a cold backend is not flooded at once.
+//
+// The factor is (elapsed / window) ^ (1 / aggression): aggression 1 is
+// linear, greater values give more traffic earlier.
+type slowStart struct {
+	window     time.Duration
+	aggression float64
+	minFactor  float64
+}
+
+// newSlowStart returns nil if slow start is disabled, nil slowStart
+// gives every backend the full factor.
+func newSlowStart(cfg SlowStartConfig) *slowStart {
+	if cfg.Window <= 0 {
+		return nil
+	}
+
+	aggression := cfg.Aggression
+	if aggression <= 0 {
+		aggression = defaultSlowStartAggression
+	}
+
+	minPercent := cfg.MinWeightPercent
+	if minPercent <= 0 {
+		minPercent = defaultSlowStartMinPercent
+	}
+
+	return &slowStart{
+		window:     cfg.Window.Duration(),
+		aggression: aggression,
+		minFactor:  min(float64(minPercent)/100, 1),
+	}
+}
+
+// factor returns the share of the full weight of bk in (0, 1].
+func (s *slowStart) factor(bk *Backend) float64 {
+	if s == nil {
+		return 1
+	}
+
+	upSince := bk.UpSince()
+	if upSince.IsZero() {
+		return 1
+	}
+
+	elapsed := time.Since(upSince)
+	if elapsed >= s.window {
+		return 1
+	}
+
+	f := math.Pow(float64(elapsed)/float64(s.window), 1/s.aggression)
+	return max(f, s.minFactor)
+}
+
+// admit reports whether a warming up backend takes this request,
+// with probability of its factor. It is used by balancers without weights.
+func (s *slowStart) admit(bk *Backend) bool {
+	f := s.factor(bk)
+	return f >= 1 || rand.Float64() < f
+}
+
+// admitKey is admit decided by the hash of the request key instead of
+// a random draw: the same key gets the same answer, and keys admitted
+// at a lower factor stay admitted as the factor grows.
+func (s *slowStart) admitKey(bk *Backend, hash uint64) bool {
+	f := s.factor(bk)
+	return f >= 1 || float64(hash%1000) < f*1000
+}
@@ -0,0 +1,115 @@
+package balancer_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowStart_RecoveredBackendGetsLessTraffic(t *testing.T) {
+	for _, typ := range balancerTypes {
+		t.Run(string(typ), func(t *testing.T) {
+			bal, err := balancer.New(zlog.NewTestLogger(), balancer.Config{
+				Type:      typ,
+				Backends:  []string{"http://a", "http://b"},
+				SlowStart: balancer.SlowStartConfig{Window: 300, MinWeightPercent: 10},
+			})
+			require.NoError(t, err)
+
+			bal.SetAlive("http://a", true)
+			time.Sleep(300 * time.Millisecond)
+			bal.SetAlive("http://b", true)
+
+			counts := make(map[string]int)
+			for i := range 200 {
+				h, err := bal.Pick(requestFrom("client-" + strconv.Itoa(i)))
+				require.NoError(t, err)
+				counts[h.Addr()]++
+				h.Done(balancer.Result{StatusCode: http.StatusOK})
+			}
+
+			assert.Less(t, counts["http://b"], 50, "warming up backend should get a small share, got %v", counts)
+		})
+	}
+}
+
+func TestSlowStart_Ramp(t *testing.T) {
+	cfg := balancer.Config{
+		Backends:  []string{"http://a", "http://b"},
+		SlowStart: balancer.SlowStartConfig{Window: 200, MinWeightPercent: 10},
+	}
+	b := balancer.NewWeightedRoundRobinBalancer(zlog.NewTestLogger(), cfg)
+
+	b.SetAlive("http://a", true)
+	time.Sleep(200 * time.Millisecond)
+	b.SetAlive("http://b", true)
+
+	share := func() int {
+		var hits int
+		for range 100 {
+			if pick(t, b, newRequest()) == "http://b" {
+				hits++
+			}
+		}
+		return hits
+	}
+
+	assert.Less(t, share(), 25, "b starts with about 10% of its weight")
+
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 50, share(), "b gets full weight after the window")
+
+	// going down and up again starts the ramp over
+	b.SetAlive("http://b", false)
+	b.SetAlive("http://b", true)
+	assert.Less(t, share(), 25)
+}
+
+func TestSlowStart_ConsistentHashKeepsKeys(t *testing.T) {
+	bal, err := balancer.New(zlog.NewTestLogger(), balancer.Config{
+		Type:      balancer.ConsistentHash,
+		Backends:  []string{"http://a", "http://b"},
+		SlowStart: balancer.SlowStartConfig{Window: 10000, MinWeightPercent: 50},
+	})
+	require.NoError(t, err)
+
+	bal.SetAlive("http://a", true)
+	bal.SetAlive("http://b", true)
+
+	// both backends are warming up, a key still goes to one backend
+	counts := make(map[string]int)
+	for i := range 200 {
+		r := requestFrom("client-" + strconv.Itoa(i))
+		addr := pick(t, bal, r)
+		counts[addr]++
+		for range 5 {
+			require.Equal(t, addr, pick(t, bal, r), "key should stick to its backend during slow start")
+		}
+	}
+	assert.Len(t, counts, 2)
+}
+
+func TestSlowStart_SingleBackend(t *testing.T) {
+	// warming up backend still takes requests if there is no other one
+	for _, typ := range balancerTypes {
+		t.Run(string(typ), func(t *testing.T) {
+			bal, err := balancer.New(zlog.NewTestLogger(), balancer.Config{
+				Type:      typ,
+				Backends:  []string{"http://a"},
+				SlowStart: balancer.SlowStartConfig{Window: 10000, MinWeightPercent: 1},
+			})
+			require.NoError(t, err)
+			bal.SetAlive("http://a", true)
+
+			for range 20 {
+				assert.Equal(t, "http://a", pick(t, bal, newRequest()))
+			}
+		})
+	}
+}
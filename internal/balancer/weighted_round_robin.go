@@ -0,0 +1,193 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/0x0FACED/zlog"
+)
+
+// weightScale multiplies weights in smooth weighted round robin.
+const weightScale = 100
+
+// weightedBackend is a backend with its static weight and the running
+// weight used by the smooth weighted round robin algorithm.
+type weightedBackend struct {
+	*Backend
+	weight  int
+	current int
+}
+
+// WeightedRoundRobinBalancer distributes requests proportionally to backend
+// weights using smooth weighted round robin (the same algorithm nginx uses).
+// Unlike naive weighted round robin it interleaves picks instead of sending
+// bursts to the heaviest backend: weights {a: 5, b: 1, c: 1} produce
+// a a b a c a a rather than a a a a a b c.
+type WeightedRoundRobinBalancer struct {
+	backends []*weightedBackend
+
+	slow   *slowStart
+	health *healthWatcher
+	log    *zlog.ZerologLogger
+
+	cfg Config
+	mu  sync.Mutex
+}
+
+func NewWeightedRoundRobinBalancer(log *zlog.ZerologLogger, cfg Config) *WeightedRoundRobinBalancer {
+	backendsList := make([]*weightedBackend, len(cfg.Backends))
+	for i, addr := range cfg.Backends {
+		backendsList[i] = &weightedBackend{
+			Backend: &Backend{
+				Addr:  addr,
+				Alive: false,
+			},
+			weight: cfg.Weight(addr),
+		}
+	}
+
+	return &WeightedRoundRobinBalancer{
+		backends: backendsList,
+		cfg:      cfg,
+		slow:     newSlowStart(cfg.SlowStart),
+		health:   newHealthWatcher(NewHealthChecker(log, cfg.HealthCheck)),
+		log:      log,
+	}
+}
+
+func (b *WeightedRoundRobinBalancer) Pick(r *http.Request) (*Handle, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		selected *weightedBackend
+		total    int
+	)
+	for _, backend := range b.backends {
+		if !usable(r, backend.Backend) {
+			continue
+		}
+
+		weight := b.effectiveWeight(backend)
+		backend.current += weight
+		total += weight
+
+		if selected == nil || backend.current > selected.current {
+			selected = backend
+		}
+	}
+
+	if selected == nil {
+		return nil, ErrNoBackends
+	}
+
+	selected.current -= total
+
+	b.log.Debug().Str("addr", selected.Addr).Int("weight", selected.weight).Msg("[WeightedRoundRobin] is selected")
+
+	return newHandle(selected.Backend, nil), nil
+}
+
+// effectiveWeight returns the weight scaled by the slow start factor.
+// Weights are multiplied by weightScale, so fractions of small weights
+// are not rounded away.
+func (b *WeightedRoundRobinBalancer) effectiveWeight(backend *weightedBackend) int {
+	return max(1, int(float64(backend.weight*weightScale)*b.slow.factor(backend.Backend)))
+}
+
+func (b *WeightedRoundRobinBalancer) Backends() []*Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backends := make([]*Backend, len(b.backends))
+	for i, backend := range b.backends {
+		backends[i] = backend.Backend
+	}
+	return backends
+}
+
+func (b *WeightedRoundRobinBalancer) SetAlive(backend string, alive bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, bk := range b.backends {
+		if bk.Addr == backend {
+			bk.SetAlive(alive)
+			b.log.Debug().Str("addr", backend).Msg("[WeightedRoundRobin] set alive")
+			return
+		}
+	}
+
+	b.log.Warn().Str("addr", backend).Msg("[WeightedRoundRobin] set alive failed: backend not found")
+}
+
+// AddBackend adds backend with weight, if weight is 0 it is taken from Config.Weights.
+func (b *WeightedRoundRobinBalancer) AddBackend(addr string, weight int) (*Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.indexOf(addr) >= 0 {
+		return nil, ErrBackendExists
+	}
+
+	if weight <= 0 {
+		weight = b.cfg.Weight(addr)
+	}
+
+	backend := &weightedBackend{
+		Backend: &Backend{
+			Addr:  addr,
+			Alive: false,
+		},
+		weight: weight,
+	}
+	b.backends = append(b.backends, backend)
+	b.health.add(backend.Backend)
+
+	b.log.Info().Str("addr", addr).Int("weight", weight).Msg("[WeightedRoundRobin] backend added")
+	return backend.Backend, nil
+}
+
+func (b *WeightedRoundRobinBalancer) RemoveBackend(addr string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.indexOf(addr)
+	if i < 0 {
+		return ErrBackendNotFound
+	}
+
+	b.health.remove(b.backends[i].Backend)
+	b.backends = slices.Delete(b.backends, i, i+1)
+
+	b.log.Info().Str("addr", addr).Msg("[WeightedRoundRobin] backend removed")
+	return nil
+}
+
+func (b *WeightedRoundRobinBalancer) indexOf(addr string) int {
+	return slices.IndexFunc(b.backends, func(bk *weightedBackend) bool {
+		return bk.Addr == addr
+	})
+}
+
+func (b *WeightedRoundRobinBalancer) SetHealthCheck(cfg HealthCheckConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b.health.setChecker(NewHealthChecker(b.log, cfg))
+	return nil
+}
+
+func (b *WeightedRoundRobinBalancer) StartHealthCheckJob(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backends := make([]*Backend, len(b.backends))
+	for i, backend := range b.backends {
+		backends[i] = backend.Backend
+	}
+	b.health.start(ctx, backends)
+}
@@ -0,0 +1,61 @@
+package balancer_test
+
+import (
+	"testing"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedRoundRobinBalancer_Pick(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b", "c"},
+		Weights: map[string]int{
+			"a": 5,
+		},
+	}
+	b := balancer.NewWeightedRoundRobinBalancer(log, cfg)
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+	b.SetAlive("c", true)
+
+	// smooth interleaving instead of a a a a a b c
+	expected := []string{"a", "a", "b", "a", "c", "a", "a"}
+	r := newRequest()
+	for i := range 2 {
+		for j, want := range expected {
+			assert.Equal(t, want, pick(t, b, r), "round %d, pick %d", i, j)
+		}
+	}
+}
+
+func TestWeightedRoundRobinBalancer_SkipsDeadBackends(t *testing.T) {
+	log := zlog.NewTestLogger()
+	cfg := balancer.Config{
+		Backends: []string{"a", "b", "c"},
+		Weights: map[string]int{
+			"a": 3,
+			"b": 2,
+		},
+	}
+	b := balancer.NewWeightedRoundRobinBalancer(log, cfg)
+
+	r := newRequest()
+	_, err := b.Pick(r)
+	assert.ErrorIs(t, err, balancer.ErrNoBackends)
+
+	b.SetAlive("a", true)
+	b.SetAlive("b", true)
+
+	counts := make(map[string]int)
+	for range 10 {
+		counts[pick(t, b, r)]++
+	}
+
+	assert.Equal(t, 6, counts["a"])
+	assert.Equal(t, 4, counts["b"])
+	assert.Equal(t, 0, counts["c"])
+}
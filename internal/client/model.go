@@ -4,4 +4,10 @@ type Client struct {
 	ID         string `json:"id"`
 	Capacity   int    `json:"capacity"`
 	RefillRate int    `json:"refill_rate"`
+	// Algorithm of the rate limiter for the client, the default
+	// one of the limiter if empty or unknown.
+	Algorithm string `json:"algorithm,omitempty"`
+	// Window in ms makes the limit Capacity requests per Window
+	// instead of RefillRate per second.
+	Window int `json:"window,omitempty"`
 }
@@ -15,9 +15,9 @@ func NewPostgresRepo(db *sql.DB) Repository {
 
 func (r *clientRepository) Create(ctx context.Context, client Client) error {
 	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO clients (id, capacity, refill_rate)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (id) DO NOTHING`, client.ID, client.Capacity, client.RefillRate)
+		INSERT INTO clients (id, capacity, refill_rate, algorithm, window_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING`, client.ID, client.Capacity, client.RefillRate, client.Algorithm, client.Window)
 
 	if err != nil {
 		return err
@@ -29,8 +29,8 @@ func (r *clientRepository) Create(ctx context.Context, client Client) error {
 func (r *clientRepository) Get(ctx context.Context, id string) (*Client, error) {
 	var client Client
 	err := r.db.QueryRowContext(ctx, `
-		SELECT id, capacity, refill_rate FROM clients WHERE id = $1`, id).
-		Scan(&client.ID, &client.Capacity, &client.RefillRate)
+		SELECT id, capacity, refill_rate, algorithm, window_ms FROM clients WHERE id = $1`, id).
+		Scan(&client.ID, &client.Capacity, &client.RefillRate, &client.Algorithm, &client.Window)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -43,8 +43,8 @@ func (r *clientRepository) Get(ctx context.Context, id string) (*Client, error)
 
 func (r *clientRepository) Update(ctx context.Context, client Client) error {
 	_, err := r.db.ExecContext(ctx, `
-		UPDATE clients SET capacity = $2, refill_rate = $3 WHERE id = $1`,
-		client.ID, client.Capacity, client.RefillRate)
+		UPDATE clients SET capacity = $2, refill_rate = $3, algorithm = $4, window_ms = $5 WHERE id = $1`,
+		client.ID, client.Capacity, client.RefillRate, client.Algorithm, client.Window)
 	if err != nil {
 		return err
 	}
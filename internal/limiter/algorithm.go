@@ -0,0 +1,299 @@
+package limiter
+
+import (
+	"math"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/client"
+)
+
+type Algorithm string
+
+const (
+	// TokenBucket refills Capacity tokens at Rate, a request takes one.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindowLog keeps times of requests and allows Capacity of them
+	// in any window. It is exact but keeps up to Capacity times per client.
+	SlidingWindowLog Algorithm = "sliding_window_log"
+	// SlidingWindowCounter weights the count of the previous fixed window
+	// by its overlap with the sliding one, two counters per client.
+	SlidingWindowCounter Algorithm = "sliding_window_counter"
+	// GCRA allows a request every 1/Rate seconds with bursts of Capacity,
+	// one timestamp per client.
+	GCRA Algorithm = "gcra"
+)
+
+// known reports whether a is a supported algorithm.
+func (a Algorithm) known() bool {
+	switch a {
+	case TokenBucket, SlidingWindowLog, SlidingWindowCounter, GCRA:
+		return true
+	}
+	return false
+}
+
+// limits are the limits of one client.
+type limits struct {
+	algorithm Algorithm
+	// capacity is the bucket size, the number of requests
+	// in the window or the burst
+	capacity int
+	// rate is requests per second
+	rate float64
+}
+
+// defaultLimits returns limits of clients without their own ones.
+func defaultLimits(cfg Config) limits {
+	l := limits{
+		algorithm: cfg.algorithm(),
+		capacity:  cfg.Capacity,
+		rate:      cfg.Rate,
+	}
+	if cfg.Window > 0 {
+		l.rate = float64(cfg.Capacity) / cfg.Window.Duration().Seconds()
+	}
+	return l
+}
+
+// clientLimits returns limits of the client from the repo. Unknown
+// or empty algorithm of the client is the default one.
+func clientLimits(cl *client.Client, cfg Config) limits {
+	l := limits{
+		algorithm: cfg.algorithm(),
+		capacity:  cl.Capacity,
+		rate:      float64(cl.RefillRate),
+	}
+	if a := Algorithm(cl.Algorithm); a.known() {
+		l.algorithm = a
+	}
+	if cl.Window > 0 {
+		l.rate = float64(cl.Capacity) / (time.Duration(cl.Window) * time.Millisecond).Seconds()
+	}
+	return l
+}
+
+// window is the time to allow capacity requests at rate.
+func (l limits) window() time.Duration {
+	return durationOf(float64(l.capacity) / l.rate)
+}
+
+// interval is the time between requests at rate.
+func (l limits) interval() time.Duration {
+	return durationOf(1 / l.rate)
+}
+
+// scale returns the share of the limits of one of nodes instances.
+func (l limits) scale(nodes int) limits {
+	if nodes <= 1 {
+		return l
+	}
+
+	l.capacity = max(1, l.capacity/nodes)
+	l.rate /= float64(nodes)
+	return l
+}
+
+// durationOf converts seconds to duration, infinite for zero rates.
+func durationOf(seconds float64) time.Duration {
+	if math.IsInf(seconds, 0) || math.IsNaN(seconds) || seconds*float64(time.Second) >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return max(1, time.Duration(seconds*float64(time.Second)))
+}
+
+// clientState is the state of one client in the in-memory limiter,
+// its methods are called with the entry lock held.
+type clientState interface {
+	// allow takes cost requests at once, all or none of them
+	allow(now time.Time, cost int) Decision
+	// reset gives the client the full allowance
+	reset(now time.Time)
+	// setLimits changes the limits keeping requests counted before now
+	setLimits(l limits, now time.Time)
+}
+
+// newState returns the state of a new client.
+func newState(l limits, now time.Time) clientState {
+	var s clientState
+	switch l.algorithm {
+	case SlidingWindowLog:
+		s = &slidingWindowLog{}
+	case SlidingWindowCounter:
+		s = &slidingWindowCounter{}
+	case GCRA:
+		s = &gcra{}
+	default:
+		// new clients of the token bucket start with the full bucket,
+		// as they do in redis
+		s = &Bucket{Tokens: float64(l.capacity), LastRefillTime: now}
+	}
+	s.setLimits(l, now)
+	return s
+}
+
+// slidingWindowLog allows limit requests in any window.
+type slidingWindowLog struct {
+	limit  int
+	window time.Duration
+	// log holds unix nanos of allowed requests in the window, oldest first
+	log []int64
+}
+
+func (s *slidingWindowLog) allow(now time.Time, cost int) Decision {
+	cutoff := now.Add(-s.window).UnixNano()
+
+	i := 0
+	for i < len(s.log) && s.log[i] <= cutoff {
+		i++
+	}
+	s.log = s.log[i:]
+
+	d := Decision{Limit: s.limit}
+	if len(s.log)+cost <= s.limit {
+		for range cost {
+			s.log = append(s.log, now.UnixNano())
+		}
+		d.Allowed = true
+	} else if cost <= s.limit {
+		// allowed once enough of the oldest requests leave the window
+		oldest := s.log[len(s.log)+cost-1-s.limit]
+		d.RetryAfter = time.Unix(0, oldest).Add(s.window).Sub(now)
+	}
+
+	d.Remaining = max(0, s.limit-len(s.log))
+	if len(s.log) > 0 {
+		d.Reset = time.Unix(0, s.log[len(s.log)-1]).Add(s.window).Sub(now)
+	}
+	return d
+}
+
+func (s *slidingWindowLog) reset(time.Time) {
+	s.log = nil
+}
+
+func (s *slidingWindowLog) setLimits(l limits, _ time.Time) {
+	s.limit = l.capacity
+	s.window = l.window()
+}
+
+// slidingWindowCounter approximates the sliding window by counts of
+// the current and the previous fixed windows aligned to unix epoch.
+type slidingWindowCounter struct {
+	limit  int
+	window time.Duration
+	// start is the number of the current window since unix epoch
+	start      int64
+	curr, prev int
+}
+
+func (s *slidingWindowCounter) allow(now time.Time, cost int) Decision {
+	idx := now.UnixNano() / int64(s.window)
+	switch {
+	case idx == s.start+1:
+		s.prev, s.curr = s.curr, 0
+	case idx > s.start+1:
+		s.prev, s.curr = 0, 0
+	}
+	s.start = max(s.start, idx)
+
+	// zero if the clock went back
+	elapsed := max(0, float64(now.UnixNano()-s.start*int64(s.window))/float64(s.window))
+	estimate := float64(s.prev)*(1-elapsed) + float64(s.curr)
+	windowEnd := time.Unix(0, (s.start+1)*int64(s.window))
+
+	d := Decision{Limit: s.limit}
+	if estimate+float64(cost) <= float64(s.limit) {
+		s.curr += cost
+		estimate += float64(cost)
+		d.Allowed = true
+	} else {
+		d.RetryAfter = s.retryAfter(now, cost, elapsed, windowEnd)
+	}
+
+	d.Remaining = max(0, int(float64(s.limit)-estimate))
+	switch {
+	case s.curr > 0:
+		// the current window weighs until the end of the next one
+		d.Reset = windowEnd.Add(s.window).Sub(now)
+	case s.prev > 0:
+		d.Reset = windowEnd.Sub(now)
+	}
+	return d
+}
+
+// retryAfter returns the time until the estimate leaves room
+// for cost requests, elapsed is the passed part of the current window.
+func (s *slidingWindowCounter) retryAfter(now time.Time, cost int, elapsed float64, windowEnd time.Time) time.Duration {
+	if free := s.limit - s.curr - cost; free >= 0 && s.prev > 0 {
+		// in this window, once the previous one weighs less
+		need := 1 - float64(free)/float64(s.prev)
+		return max(1, time.Duration((need-elapsed)*float64(s.window)))
+	}
+
+	if s.limit < cost || s.curr == 0 {
+		return 0
+	}
+
+	// in the next window, where the current one is the previous
+	need := max(0, 1-float64(s.limit-cost)/float64(s.curr))
+	return windowEnd.Sub(now) + time.Duration(need*float64(s.window))
+}
+
+func (s *slidingWindowCounter) reset(time.Time) {
+	s.prev, s.curr = 0, 0
+}
+
+func (s *slidingWindowCounter) setLimits(l limits, _ time.Time) {
+	window := l.window()
+	if window != s.window {
+		// windows of the old size don't line up with the new ones
+		s.start, s.prev, s.curr = 0, 0, 0
+	}
+
+	s.limit = l.capacity
+	s.window = window
+}
+
+// gcra is the generic cell rate algorithm: requests are spaced by
+// interval, burst of them may come at once.
+type gcra struct {
+	interval time.Duration
+	burst    int
+	// tat is the theoretical arrival time of the next request
+	tat time.Time
+}
+
+func (g *gcra) allow(now time.Time, cost int) Decision {
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	// in floats, burst*interval overflows for tiny rates
+	tolerance := float64(g.burst) * float64(g.interval)
+
+	d := Decision{Limit: g.burst}
+	next := tat.Add(time.Duration(cost) * g.interval)
+	if wait := float64(next.Sub(now)) - tolerance; wait > 0 {
+		if cost <= g.burst {
+			d.RetryAfter = time.Duration(wait)
+		}
+	} else {
+		g.tat = next
+		tat = next
+		d.Allowed = true
+	}
+
+	d.Remaining = max(0, int((tolerance-float64(tat.Sub(now)))/float64(g.interval)))
+	d.Reset = tat.Sub(now)
+	return d
+}
+
+func (g *gcra) reset(time.Time) {
+	g.tat = time.Time{}
+}
+
+func (g *gcra) setLimits(l limits, _ time.Time) {
+	g.interval = l.interval()
+	g.burst = l.capacity
+}
@@ -0,0 +1,225 @@
+package limiter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/client"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/load-balancer/internal/limiter/mocks"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var windowAlgorithms = []limiter.Algorithm{
+	limiter.SlidingWindowLog,
+	limiter.SlidingWindowCounter,
+	limiter.GCRA,
+}
+
+// newLimiters returns in-memory and redis limiters with cfg.
+func newLimiters(t *testing.T, repo limiter.Repository, cfg limiter.Config) map[string]limiter.RateLimitter {
+	t.Helper()
+
+	redis, _ := newRedisLimiter(t, repo, cfg)
+
+	cfg.Type = limiter.Memory
+	memory, err := limiter.New(zlog.NewTestLogger(), repo, cfg, limiter.RedisConfig{})
+	require.NoError(t, err)
+
+	return map[string]limiter.RateLimitter{"memory": memory, "redis": redis}
+}
+
+func TestAlgorithms_RequestsPerWindow(t *testing.T) {
+	for _, alg := range windowAlgorithms {
+		cfg := limiter.Config{Algorithm: alg, Capacity: 3, Window: 300, TTL: 60}
+
+		for name, lim := range newLimiters(t, nil, cfg) {
+			t.Run(string(alg)+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+
+				for range 3 {
+					assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+				}
+				assert.False(t, lim.Allow(ctx, "user1", 1).Allowed, "should not allow more than capacity per window")
+				assert.True(t, lim.Allow(ctx, "user2", 1).Allowed, "clients have their own limits")
+
+				time.Sleep(650 * time.Millisecond)
+				assert.True(t, lim.Allow(ctx, "user1", 1).Allowed, "requests should be allowed in the next windows")
+
+				lim.Reset("user2")
+				for range 3 {
+					assert.True(t, lim.Allow(ctx, "user2", 1).Allowed, "full limit should be available after reset")
+				}
+			})
+		}
+	}
+}
+
+func TestAlgorithms_Decision(t *testing.T) {
+	const window = 10 * time.Second
+
+	for _, alg := range windowAlgorithms {
+		cfg := limiter.Config{Algorithm: alg, Capacity: 2, Window: 10_000}
+
+		for name, lim := range newLimiters(t, nil, cfg) {
+			t.Run(string(alg)+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+
+				d := lim.Allow(ctx, "user1", 1)
+				assert.True(t, d.Allowed)
+				assert.Equal(t, 2, d.Limit)
+				assert.Equal(t, 1, d.Remaining)
+				assert.Zero(t, d.RetryAfter, "allowed request should not wait")
+
+				d = lim.Allow(ctx, "user1", 1)
+				assert.True(t, d.Allowed)
+				assert.Equal(t, 0, d.Remaining)
+
+				d = lim.Allow(ctx, "user1", 1)
+				assert.False(t, d.Allowed)
+				assert.Equal(t, 2, d.Limit)
+				assert.Equal(t, 0, d.Remaining)
+				assert.Greater(t, d.RetryAfter, time.Duration(0))
+				assert.LessOrEqual(t, d.RetryAfter, 2*window)
+				assert.Greater(t, d.Reset, time.Duration(0))
+				assert.LessOrEqual(t, d.Reset, 2*window)
+			})
+		}
+	}
+}
+
+func TestAlgorithms_Cost(t *testing.T) {
+	for _, alg := range windowAlgorithms {
+		cfg := limiter.Config{Algorithm: alg, Capacity: 5, Window: 10_000}
+
+		for name, lim := range newLimiters(t, nil, cfg) {
+			t.Run(string(alg)+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+
+				d := lim.Allow(ctx, "user1", 3)
+				assert.True(t, d.Allowed)
+				assert.Equal(t, 2, d.Remaining)
+
+				d = lim.Allow(ctx, "user1", 3)
+				assert.False(t, d.Allowed, "cost above the remaining limit is not taken in part")
+				assert.Greater(t, d.RetryAfter, time.Duration(0))
+
+				d = lim.Allow(ctx, "user1", 2)
+				assert.True(t, d.Allowed)
+				assert.Equal(t, 0, d.Remaining)
+
+				d = lim.Allow(ctx, "user2", 6)
+				assert.False(t, d.Allowed, "cost above the capacity is never allowed")
+				assert.Zero(t, d.RetryAfter)
+				assert.True(t, lim.Allow(ctx, "user2", 5).Allowed)
+			})
+		}
+	}
+}
+
+func TestAlgorithms_SlidingWindowLog(t *testing.T) {
+	cfg := limiter.Config{Algorithm: limiter.SlidingWindowLog, Capacity: 2, Window: 400}
+
+	for name, lim := range newLimiters(t, nil, cfg) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+			time.Sleep(200 * time.Millisecond)
+			assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+			assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+
+			// the first request leaves the window, the second one doesn't
+			time.Sleep(250 * time.Millisecond)
+			assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+			assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+		})
+	}
+}
+
+func TestAlgorithms_GCRA(t *testing.T) {
+	cfg := limiter.Config{Algorithm: limiter.GCRA, Capacity: 2, Rate: 10}
+
+	for name, lim := range newLimiters(t, nil, cfg) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+			assert.True(t, lim.Allow(ctx, "user1", 1).Allowed, "burst of capacity is allowed")
+			assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+
+			// one request per 100ms
+			time.Sleep(120 * time.Millisecond)
+			assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+			assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+		})
+	}
+}
+
+func TestAlgorithms_PerClient(t *testing.T) {
+	mockRepo := new(mocks.MockRepo)
+	mockRepo.On("Get", mock.Anything, "billing").
+		Return(&client.Client{ID: "billing", Capacity: 3, Algorithm: string(limiter.SlidingWindowLog), Window: 60000}, nil)
+	mockRepo.On("Get", mock.Anything, "user1").Return(nil, nil)
+	mockRepo.On("Close").Return(nil)
+
+	cfg := limiter.Config{Capacity: 1, Rate: 1, TTL: 60}
+	redis, mr := newRedisLimiter(t, mockRepo, cfg)
+
+	cfg.Type = limiter.Memory
+	memory, err := limiter.New(zlog.NewTestLogger(), mockRepo, cfg, limiter.RedisConfig{})
+	require.NoError(t, err)
+
+	for name, lim := range map[string]limiter.RateLimitter{"memory": memory, "redis": redis} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+			assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+
+			for range 3 {
+				assert.True(t, lim.Allow(ctx, "billing", 1).Allowed)
+			}
+			assert.False(t, lim.Allow(ctx, "billing", 1).Allowed, "3 requests per minute")
+		})
+	}
+
+	assert.True(t, mr.Exists("rate_limit:user1"))
+	assert.True(t, mr.Exists("rate_limit:sliding_window_log:billing"))
+}
+
+func TestAlgorithms_UpdateConfig(t *testing.T) {
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 5, Rate: 1})
+	ctx := context.Background()
+
+	assert.True(t, lim.Allow(ctx, "user1", 5).Allowed)
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+
+	// clients with default limits start over with the new algorithm
+	lim.UpdateConfig(limiter.Config{Algorithm: limiter.GCRA, Capacity: 2, Rate: 1})
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+}
+
+func TestAlgorithms_NewClientCost(t *testing.T) {
+	cfg := limiter.Config{Capacity: 5, Rate: 1, TTL: 60}
+	redis, _ := newRedisLimiter(t, nil, cfg)
+	memory := limiter.NewInMemoryLimiter(nil, cfg)
+
+	for name, lim := range map[string]limiter.RateLimitter{"memory": memory, "redis": redis} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// new clients start with the full bucket in both limiters
+			d := lim.Allow(ctx, "user1", 4)
+			assert.True(t, d.Allowed)
+			assert.Equal(t, 1, d.Remaining)
+			assert.False(t, lim.Allow(ctx, "user1", 2).Allowed)
+		})
+	}
+}
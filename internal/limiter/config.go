@@ -1,8 +1,203 @@
 package limiter
 
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/duration"
+	"github.com/0x0FACED/load-balancer/internal/pkg/validate"
+)
+
+type LimiterType string
+
+const (
+	// Memory keeps buckets in the process, every instance has its own limits.
+	Memory LimiterType = "memory"
+	// Redis keeps buckets in redis shared by all instances.
+	Redis LimiterType = "redis"
+)
+
 type Config struct {
-	Capacity        int `json:"capacity"`
-	Rate            int `json:"rate"`
-	RefillIntrerval int `json:"refill_interval"`
-	TTL             int `json:"ttl"`
+	// Type is "memory" (default) or "redis".
+	Type LimiterType `json:"type"`
+	// Algorithm limits clients without their own algorithm
+	// in the clients table, "token_bucket" by default.
+	Algorithm Algorithm `json:"algorithm"`
+	// Capacity is the bucket size, the number of requests
+	// in the window or the burst of GCRA.
+	Capacity int `json:"capacity"`
+	// Rate is tokens per second, may be fractional like 0.5.
+	Rate float64 `json:"rate"`
+	// Window makes the limit Capacity requests per Window,
+	// it overrides Rate with Capacity/Window.
+	Window duration.Milliseconds `json:"window"`
+	// RefillIntrerval is not used anymore, buckets are refilled on
+	// request. It is accepted for compatibility of config files.
+	RefillIntrerval duration.Milliseconds `json:"refill_interval"`
+	// TTL of the client bucket without requests, numbers are seconds.
+	// Idle buckets are removed after it, zero keeps them.
+	TTL duration.Seconds `json:"ttl"`
+	// MaxClients caps the number of in-memory buckets, the least
+	// recently used ones are removed above it. 1000000 by default.
+	MaxClients int `json:"max_clients"`
+	// Fallback is used by the redis limiter when redis is unavailable.
+	Fallback FallbackConfig `json:"fallback"`
+	// Costs are tokens taken by requests, the first matching rule wins.
+	// Requests matching no rule cost one token.
+	Costs []CostRule `json:"costs"`
+}
+
+// CostRule makes requests matching Method and Path cost Cost tokens.
+// Empty fields match anything.
+type CostRule struct {
+	// Method is compared with the request method, case-insensitive.
+	Method string `json:"method"`
+	// Path is a pattern of path.Match, like "/export/*".
+	// "*" doesn't match "/", so it matches a single segment.
+	Path string `json:"path"`
+	Cost int    `json:"cost"`
+}
+
+func (r CostRule) Validate() error {
+	var pathErr error
+	if _, err := path.Match(r.Path, ""); err != nil {
+		pathErr = validate.Errorf("path", "invalid pattern %q", r.Path)
+	}
+
+	return errors.Join(
+		pathErr,
+		validate.Positive("cost", r.Cost),
+	)
+}
+
+// Matches reports whether the request with method and path matches the rule.
+func (r CostRule) Matches(method, urlPath string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+
+	ok, _ := path.Match(r.Path, urlPath)
+	return ok
+}
+
+// FallbackConfig controls falling back from redis to the in-memory limiter.
+type FallbackConfig struct {
+	// Nodes is the number of balancer instances, limits of the local
+	// fallback are divided by it to keep the total close to the shared one.
+	// Zero or one keeps full limits on every instance.
+	Nodes int `json:"nodes"`
+	// FailureThreshold is the number of redis errors in a row after which
+	// requests stop going to redis, 3 by default.
+	FailureThreshold int `json:"failure_threshold"`
+	// Cooldown is the time before redis is probed again, 5s by default.
+	Cooldown duration.Milliseconds `json:"cooldown"`
+	// Timeout of a single redis call, 100ms by default.
+	Timeout duration.Milliseconds `json:"timeout"`
+}
+
+func (c FallbackConfig) Validate() error {
+	return errors.Join(
+		validate.NonNegative("nodes", c.Nodes),
+		validate.NonNegative("failure_threshold", c.FailureThreshold),
+		validate.NonNegative("cooldown", c.Cooldown),
+		validate.NonNegative("timeout", c.Timeout),
+	)
+}
+
+const (
+	defaultFailureThreshold = 3
+	defaultCooldown         = 5 * time.Second
+	defaultRedisTimeout     = 100 * time.Millisecond
+)
+
+func (c FallbackConfig) failureThreshold() int {
+	if c.FailureThreshold == 0 {
+		return defaultFailureThreshold
+	}
+	return c.FailureThreshold
+}
+
+func (c FallbackConfig) cooldown() time.Duration {
+	if c.Cooldown == 0 {
+		return defaultCooldown
+	}
+	return c.Cooldown.Duration()
+}
+
+func (c FallbackConfig) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return defaultRedisTimeout
+	}
+	return c.Timeout.Duration()
+}
+
+// Validate checks that the type is known and limits are positive.
+func (c Config) Validate() error {
+	var typeErr error
+	switch c.Type {
+	case "", Memory, Redis:
+	default:
+		typeErr = validate.Errorf("type", "unknown rate limiter type %q", c.Type)
+	}
+
+	var algErr error
+	if c.Algorithm != "" && !c.Algorithm.known() {
+		algErr = validate.Errorf("algorithm", "unknown rate limiter algorithm %q", c.Algorithm)
+	}
+
+	// rate is not used if the window is set
+	rateErr := validate.Positive("window", c.Window)
+	if c.Window == 0 {
+		rateErr = validate.Positive("rate", c.Rate)
+	}
+
+	var costErrs []error
+	for i, rule := range c.Costs {
+		costErrs = append(costErrs, validate.Field(validate.Index("costs", i), rule.Validate()))
+	}
+
+	return errors.Join(
+		typeErr,
+		algErr,
+		validate.Positive("capacity", c.Capacity),
+		rateErr,
+		validate.NonNegative("refill_interval", c.RefillIntrerval),
+		validate.NonNegative("ttl", c.TTL),
+		validate.NonNegative("max_clients", c.MaxClients),
+		validate.Field("fallback", c.Fallback.Validate()),
+		errors.Join(costErrs...),
+	)
+}
+
+func (c Config) algorithm() Algorithm {
+	if c.Algorithm == "" {
+		return TokenBucket
+	}
+	return c.Algorithm
+}
+
+// RedisConfig is the connection to redis used by the redis limiter.
+type RedisConfig struct {
+	Address      string                `json:"address"`
+	Password     string                `json:"password"`
+	DB           int                   `json:"db"`
+	PoolSize     int                   `json:"pool_size"`
+	DialTimeout  duration.Milliseconds `json:"dial_timeout"`
+	ReadTimeout  duration.Milliseconds `json:"read_timeout"`
+	WriteTimeout duration.Milliseconds `json:"write_timeout"`
+}
+
+func (c RedisConfig) Validate() error {
+	return errors.Join(
+		validate.NonNegative("db", c.DB),
+		validate.NonNegative("pool_size", c.PoolSize),
+		validate.NonNegative("dial_timeout", c.DialTimeout),
+		validate.NonNegative("read_timeout", c.ReadTimeout),
+		validate.NonNegative("write_timeout", c.WriteTimeout),
+	)
 }
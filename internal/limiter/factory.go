@@ -0,0 +1,45 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/0x0FACED/zlog"
+)
+
+// redisPingTimeout limits the connection check at startup.
+const redisPingTimeout = 5 * time.Second
+
+// New creates rate limiter of type cfg.Type. Clients limits are read
+// from repo, redisCfg is used by the redis limiter only. If redis is
+// unavailable at start, the redis limiter starts on its in-memory
+// fallback and switches to redis once it responds.
+func New(log *zlog.ZerologLogger, repo Repository, cfg Config, redisCfg RedisConfig) (RateLimitter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case Redis:
+		cl := NewRedisClient(redisCfg)
+
+		fallback := NewInMemoryLimiter(repo, cfg)
+		fallback.nodes = cfg.Fallback.Nodes
+		rl := NewRedisLimiter(cl, fallback, log, repo, cfg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), redisPingTimeout)
+		defer cancel()
+
+		if err := cl.Ping(ctx).Err(); err != nil {
+			// requests don't wait for redis until the breaker probes it
+			rl.breaker.trip()
+			log.Warn().Err(err).Str("address", redisCfg.Address).Msg("[RateLimiter] redis unavailable, starting with in-memory fallback")
+		}
+
+		log.Info().Str("address", redisCfg.Address).Str("algorithm", string(cfg.algorithm())).Msg("[RateLimiter] using redis limiter")
+		return rl, nil
+	default:
+		log.Info().Str("algorithm", string(cfg.algorithm())).Msg("[RateLimiter] using in-memory limiter")
+		return NewInMemoryLimiter(repo, cfg), nil
+	}
+}
@@ -1,110 +1,281 @@
 package limiter
 
 import (
+	"container/list"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/0x0FACED/load-balancer/internal/client"
 	_ "github.com/lib/pq"
 )
 
+const (
+	// shardCount is the number of bucket maps, clients are spread
+	// over them by hash of the id to cut lock contention.
+	shardCount = 64
+
+	// defaultMaxClients caps tracked clients if Config.MaxClients is 0.
+	defaultMaxClients = 1_000_000
+	// maxEvictionInterval is the longest time between sweeps of idle buckets.
+	maxEvictionInterval = time.Minute
+)
+
+// Bucket is the state of a client of the token bucket algorithm.
 type Bucket struct {
-	Capacity       int
-	Tokens         int
-	RefillRate     int
+	Capacity int
+	// Tokens is fractional, parts of a token refilled at low
+	// rates are kept until they sum up to a whole one.
+	Tokens float64
+	// RefillRate is tokens per second.
+	RefillRate     float64
 	LastRefillTime time.Time
+}
+
+// refill adds tokens for the time passed since the last refill.
+func (b *Bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.LastRefillTime).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	b.Tokens = min(float64(b.Capacity), b.Tokens+elapsed*b.RefillRate)
+	b.LastRefillTime = now
+}
+
+func (b *Bucket) allow(now time.Time, cost int) Decision {
+	b.refill(now)
+
+	d := Decision{Limit: b.Capacity}
+	if b.Tokens >= float64(cost) {
+		b.Tokens -= float64(cost)
+		d.Allowed = true
+	} else if b.RefillRate > 0 && cost <= b.Capacity {
+		d.RetryAfter = durationOf((float64(cost) - b.Tokens) / b.RefillRate)
+	}
+
+	d.Remaining = int(b.Tokens)
+	if b.RefillRate > 0 && b.Tokens < float64(b.Capacity) {
+		d.Reset = durationOf((float64(b.Capacity) - b.Tokens) / b.RefillRate)
+	}
+	return d
+}
+
+func (b *Bucket) reset(now time.Time) {
+	b.Tokens = float64(b.Capacity)
+	b.LastRefillTime = now
+}
+
+// setLimits changes the limits, tokens up to now are refilled at the
+// old rate and tokens above the new capacity are dropped.
+func (b *Bucket) setLimits(l limits, now time.Time) {
+	b.refill(now)
+	b.Capacity = l.capacity
+	b.RefillRate = l.rate
+	b.Tokens = min(b.Tokens, float64(l.capacity))
+}
 
-	mu sync.Mutex
+// bucketEntry is a client in the shard LRU list.
+type bucketEntry struct {
+	clientID string
+	// lastUsed is guarded by the shard lock
+	lastUsed time.Time
+
+	// state, algorithm and defaults are guarded by mu
+	state     clientState
+	algorithm Algorithm
+	// defaults is set if the client uses the default limits
+	// of the limiter, not its own ones
+	defaults bool
+	mu       sync.Mutex
+}
+
+type bucketShard struct {
+	buckets map[string]*list.Element
+	// lru holds *bucketEntry, the front is the most recently used
+	lru *list.List
+	mu  sync.Mutex
 }
 
-type TokenBucketLimiter struct {
-	buckets map[string]*Bucket
-	repo    Repository
-	cfg     Config
+// Stats are counters of the limiter buckets.
+type Stats struct {
+	// Buckets is the number of tracked clients.
+	Buckets int64 `json:"buckets"`
+	// EvictedIdle is the number of buckets removed after TTL without requests.
+	EvictedIdle uint64 `json:"evicted_idle"`
+	// EvictedLRU is the number of least recently used buckets
+	// removed to stay within MaxClients.
+	EvictedLRU uint64 `json:"evicted_lru"`
+}
+
+// InMemoryLimiter keeps state of clients in memory. Every client is
+// limited by the algorithm from the clients repo or Config.Algorithm.
+// State is updated lazily on Allow by the time passed since the
+// previous request, so idle clients cost nothing.
+//
+// Memory is bounded: buckets idle for Config.TTL are removed by the
+// eviction job and the number of buckets is capped by Config.MaxClients,
+// the least recently used ones are removed first. A removed client
+// starts with a new bucket.
+type InMemoryLimiter struct {
+	shards [shardCount]bucketShard
+	repo   Repository
+
+	buckets     atomic.Int64
+	evictedIdle atomic.Uint64
+	evictedLRU  atomic.Uint64
 
-	refillCancel context.CancelFunc
-	mu           sync.RWMutex
+	// cfg and nodes are guarded by mu
+	cfg Config
+	// nodes is the number of instances sharing the limits, they are
+	// divided by it. It is set for the fallback of the redis limiter.
+	nodes int
+	mu    sync.RWMutex
 }
 
-func NewTokenBucketLimiter(repo Repository, cfg Config) *TokenBucketLimiter {
-	return &TokenBucketLimiter{
-		buckets: make(map[string]*Bucket),
-		repo:    repo,
-		cfg:     cfg,
+func NewInMemoryLimiter(repo Repository, cfg Config) *InMemoryLimiter {
+	rl := &InMemoryLimiter{
+		repo: repo,
+		cfg:  cfg,
 	}
+	for i := range rl.shards {
+		rl.shards[i].buckets = make(map[string]*list.Element)
+		rl.shards[i].lru = list.New()
+	}
+	return rl
 }
 
-func (rl *TokenBucketLimiter) Allow(ctx context.Context, clientID string) bool {
-	rl.mu.RLock()
-	bucket, exists := rl.buckets[clientID]
-	rl.mu.RUnlock()
+func (rl *InMemoryLimiter) Allow(ctx context.Context, clientID string, cost int) Decision {
+	shard := rl.shard(clientID)
+	now := time.Now()
+
+	shard.mu.Lock()
+	entry := shard.touch(clientID, now)
+	shard.mu.Unlock()
+
+	if entry == nil {
+		// the repo is asked without the lock, so a slow query
+		// doesn't block other clients of the shard
+		cl, err := rl.clientConfig(ctx, clientID)
+		if err != nil {
+			return Decision{}
+		}
 
-	if !exists {
 		// double-check locking
-		rl.mu.Lock()
-		bucket, exists = rl.buckets[clientID]
-		if !exists {
-			cl, err := rl.repo.Get(ctx, clientID)
-			if err != nil {
-				rl.mu.Unlock()
-				return false
-			}
-			if cl == nil {
-				bucket = &Bucket{
-					Capacity:       rl.cfg.Capacity,
-					RefillRate:     rl.cfg.Rate,
-					Tokens:         1,
-					LastRefillTime: time.Now(),
-				}
-				rl.buckets[clientID] = bucket
-			} else {
-				bucket = &Bucket{
-					Capacity:       cl.Capacity,
-					RefillRate:     cl.RefillRate,
-					Tokens:         1,
-					LastRefillTime: time.Now(),
-				}
-				rl.buckets[clientID] = bucket
-			}
+		shard.mu.Lock()
+		entry = shard.touch(clientID, now)
+		if entry == nil {
+			entry = rl.newEntry(clientID, cl, now)
+			rl.add(shard, entry)
 		}
-		rl.mu.Unlock()
+		shard.mu.Unlock()
 	}
 
-	bucket.mu.Lock()
-	defer bucket.mu.Unlock()
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	return entry.state.allow(now, max(1, cost))
+}
 
-	if bucket.Tokens > 0 {
-		bucket.Tokens--
-		return true
+// clientConfig returns limits of the client, nil if there are no
+// client limits or no repo.
+func (rl *InMemoryLimiter) clientConfig(ctx context.Context, clientID string) (*client.Client, error) {
+	if rl.repo == nil {
+		return nil, nil
 	}
 
-	return false
+	return rl.repo.Get(ctx, clientID)
 }
 
-func (rl *TokenBucketLimiter) Reset(clientID string) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// newEntry returns a new client with limits of cl,
+// the default ones if cl is nil.
+func (rl *InMemoryLimiter) newEntry(clientID string, cl *client.Client, now time.Time) *bucketEntry {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
 
-	bucket, exists := rl.buckets[clientID]
-	if exists {
-		bucket.Tokens = bucket.Capacity
-		bucket.LastRefillTime = time.Now()
-	} else {
-		bucket = &Bucket{
-			Capacity:       rl.cfg.Capacity, // default capacity
-			Tokens:         rl.cfg.Capacity, // default tokens
-			RefillRate:     rl.cfg.Rate,     // default refill rate
-			LastRefillTime: time.Now(),
-		}
-		rl.buckets[clientID] = bucket
+	l := defaultLimits(rl.cfg)
+	if cl != nil {
+		l = clientLimits(cl, rl.cfg)
+	}
+	l = l.scale(rl.nodes)
+
+	return &bucketEntry{
+		clientID:  clientID,
+		lastUsed:  now,
+		state:     newState(l, now),
+		algorithm: l.algorithm,
+		defaults:  cl == nil,
 	}
 }
 
-func (rl *TokenBucketLimiter) Stop() error {
-	if rl.refillCancel != nil {
-		rl.refillCancel()
+// add adds the client as the most recently used one and evicts
+// the least recently used clients above the shard capacity.
+// shard.mu must be held.
+func (rl *InMemoryLimiter) add(shard *bucketShard, entry *bucketEntry) {
+	shard.buckets[entry.clientID] = shard.lru.PushFront(entry)
+	rl.buckets.Add(1)
+
+	limit := rl.shardCapacity()
+	for shard.lru.Len() > limit {
+		shard.remove(shard.lru.Back())
+		rl.buckets.Add(-1)
+		rl.evictedLRU.Add(1)
 	}
+}
+
+// shardCapacity is the max number of buckets in one shard.
+func (rl *InMemoryLimiter) shardCapacity() int {
+	rl.mu.RLock()
+	maxClients := rl.cfg.MaxClients
+	rl.mu.RUnlock()
 
+	if maxClients == 0 {
+		maxClients = defaultMaxClients
+	}
+	return max(1, (maxClients+shardCount-1)/shardCount)
+}
+
+// touch returns the client and marks it used at now,
+// nil if there is no such client. s.mu must be held.
+func (s *bucketShard) touch(clientID string, now time.Time) *bucketEntry {
+	e, ok := s.buckets[clientID]
+	if !ok {
+		return nil
+	}
+
+	entry := e.Value.(*bucketEntry)
+	entry.lastUsed = now
+	s.lru.MoveToFront(e)
+	return entry
+}
+
+// remove removes the client of the list element. s.mu must be held.
+func (s *bucketShard) remove(e *list.Element) {
+	entry := s.lru.Remove(e).(*bucketEntry)
+	delete(s.buckets, entry.clientID)
+}
+
+func (rl *InMemoryLimiter) Reset(clientID string) {
+	shard := rl.shard(clientID)
+	now := time.Now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry := shard.touch(clientID, now)
+	if entry == nil {
+		// default limits until the client shows up
+		entry = rl.newEntry(clientID, nil, now)
+		rl.add(shard, entry)
+	}
+
+	entry.mu.Lock()
+	entry.state.reset(now)
+	entry.mu.Unlock()
+}
+
+func (rl *InMemoryLimiter) Stop() error {
 	if rl.repo != nil {
 		if err := rl.repo.Close(); err != nil {
 			return err
@@ -114,38 +285,128 @@ func (rl *TokenBucketLimiter) Stop() error {
 	return nil
 }
 
-func (rl *TokenBucketLimiter) StartRefillJob(ctx context.Context) {
-	ctx, cancel := context.WithCancel(ctx)
-	rl.refillCancel = cancel
+// UpdateConfig replaces the default limits. Clients with the default
+// limits get the new ones keeping requests counted so far, tokens above
+// the new capacity are dropped. If the default algorithm is changed,
+// they start over with the new one.
+func (rl *InMemoryLimiter) UpdateConfig(cfg Config) {
+	rl.mu.Lock()
+	rl.cfg = cfg
+	l := defaultLimits(cfg).scale(rl.nodes)
+	rl.mu.Unlock()
+
+	now := time.Now()
+	for i := range rl.shards {
+		shard := &rl.shards[i]
+
+		shard.mu.Lock()
+		for _, e := range shard.buckets {
+			entry := e.Value.(*bucketEntry)
+
+			entry.mu.Lock()
+			if entry.defaults {
+				if entry.algorithm == l.algorithm {
+					entry.state.setLimits(l, now)
+				} else {
+					entry.state = newState(l, now)
+					entry.algorithm = l.algorithm
+				}
+			}
+			entry.mu.Unlock()
+		}
+		shard.mu.Unlock()
+	}
+}
 
+// setNodes sets the number of instances sharing the limits,
+// it applies to clients added after the call.
+func (rl *InMemoryLimiter) setNodes(nodes int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.nodes = nodes
+}
+
+// StartEvictionJob removes buckets idle for Config.TTL until ctx is done,
+// zero TTL keeps idle buckets. Buckets are refilled lazily on Allow,
+// so there is no refill job.
+func (rl *InMemoryLimiter) StartEvictionJob(ctx context.Context) {
 	go func() {
-		ticker := time.NewTicker(time.Duration(rl.cfg.RefillIntrerval) * time.Millisecond)
-		defer ticker.Stop()
+		timer := time.NewTimer(rl.evictionInterval())
+		defer timer.Stop()
+
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				rl.refillBuckets()
+			case <-timer.C:
+				rl.evictIdle(time.Now())
+				// TTL may be changed by UpdateConfig
+				timer.Reset(rl.evictionInterval())
 			}
 		}
 	}()
 }
 
-func (rl *TokenBucketLimiter) refillBuckets() {
+// evictionInterval is half of the TTL, so buckets live at most 1.5 TTL.
+func (rl *InMemoryLimiter) evictionInterval() time.Duration {
 	rl.mu.RLock()
-	defer rl.mu.RUnlock()
+	ttl := rl.cfg.TTL.Duration()
+	rl.mu.RUnlock()
 
-	for _, bucket := range rl.buckets {
-		bucket.mu.Lock()
+	if ttl == 0 {
+		return maxEvictionInterval
+	}
+	return min(ttl/2, maxEvictionInterval)
+}
 
-		elapsed := time.Since(bucket.LastRefillTime).Seconds()
-		tokensToAdd := int(elapsed * float64(bucket.RefillRate))
-		if tokensToAdd > 0 {
-			bucket.Tokens = min(bucket.Capacity, bucket.Tokens+tokensToAdd)
-			bucket.LastRefillTime = time.Now()
+// evictIdle removes buckets not used for TTL before now. Shards are
+// ordered by last use, so only the evicted buckets are visited.
+func (rl *InMemoryLimiter) evictIdle(now time.Time) {
+	rl.mu.RLock()
+	ttl := rl.cfg.TTL.Duration()
+	rl.mu.RUnlock()
+
+	if ttl == 0 {
+		return
+	}
+
+	for i := range rl.shards {
+		shard := &rl.shards[i]
+
+		shard.mu.Lock()
+		for e := shard.lru.Back(); e != nil; e = shard.lru.Back() {
+			if now.Sub(e.Value.(*bucketEntry).lastUsed) < ttl {
+				break
+			}
+			shard.remove(e)
+			rl.buckets.Add(-1)
+			rl.evictedIdle.Add(1)
 		}
+		shard.mu.Unlock()
+	}
+}
+
+// Stats returns the number of buckets and evictions.
+func (rl *InMemoryLimiter) Stats() Stats {
+	return Stats{
+		Buckets:     rl.buckets.Load(),
+		EvictedIdle: rl.evictedIdle.Load(),
+		EvictedLRU:  rl.evictedLRU.Load(),
+	}
+}
+
+// shard returns the shard of the client by FNV-1a hash of its id.
+func (rl *InMemoryLimiter) shard(clientID string) *bucketShard {
+	const (
+		offset = 2166136261
+		prime  = 16777619
+	)
 
-		bucket.mu.Unlock()
+	h := uint32(offset)
+	for i := 0; i < len(clientID); i++ {
+		h ^= uint32(clientID[i])
+		h *= prime
 	}
+	return &rl.shards[h%shardCount]
 }
@@ -0,0 +1,37 @@
+package limiter
+
+import (
+	"github.com/0x0FACED/zlog"
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenBucketLimiter is the previous name of InMemoryLimiter.
+//
+// Deprecated: use InMemoryLimiter.
+type TokenBucketLimiter = InMemoryLimiter
+
+// NewTokenBucketLimiter is the previous name of NewInMemoryLimiter.
+//
+// Deprecated: use NewInMemoryLimiter.
+func NewTokenBucketLimiter(repo Repository, cfg Config) *TokenBucketLimiter {
+	return NewInMemoryLimiter(repo, cfg)
+}
+
+// RedisTokenBucketLimiter is the previous name of RedisLimiter.
+//
+// Deprecated: use RedisLimiter.
+type RedisTokenBucketLimiter = RedisLimiter
+
+// NewRedisTocketBucketLimiter is the previous name of NewRedisLimiter.
+//
+// Per-client overrides are disabled, as they were before.
+//
+// Deprecated: use NewRedisLimiter.
+func NewRedisTocketBucketLimiter(
+	client *redis.Client,
+	inMem *InMemoryLimiter,
+	log *zlog.ZerologLogger,
+	cfg Config,
+) *RedisTokenBucketLimiter {
+	return NewRedisLimiter(client, inMem, log, nil, cfg)
+}
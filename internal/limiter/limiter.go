@@ -1,10 +1,38 @@
 package limiter
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type RateLimitter interface {
-	Allow(ctx context.Context, clientID string) bool
+	// Allow takes cost tokens of the client at once, a request of
+	// the cost above the client capacity is never allowed.
+	// Cost below 1 is 1.
+	Allow(ctx context.Context, clientID string, cost int) Decision
 	Reset(clientID string)
-	StartRefillJob(ctx context.Context)
+	// StartEvictionJob starts removing idle buckets kept
+	// in memory until ctx is done.
+	StartEvictionJob(ctx context.Context)
+	// UpdateConfig replaces the default limits at runtime. Clients
+	// without their own limits get them right away.
+	UpdateConfig(cfg Config)
+	// Stats returns counters of buckets kept in memory.
+	Stats() Stats
 	Stop() error
 }
+
+// Decision is the result of Allow with the state of the client limit.
+// Limit is zero if the limit is unknown, e.g. the clients repo failed.
+type Decision struct {
+	Allowed bool
+	// Limit is the capacity: requests in a burst or in the window.
+	Limit int
+	// Remaining is the number of requests allowed right now.
+	Remaining int
+	// Reset is the time until the client has the full limit again.
+	Reset time.Duration
+	// RetryAfter is the time until the request of the same cost may be
+	// allowed, zero for allowed requests or if it never will be.
+	RetryAfter time.Duration
+}
@@ -2,68 +2,270 @@ package limiter_test
 
 import (
 	"context"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/0x0FACED/load-balancer/internal/client"
 	"github.com/0x0FACED/load-balancer/internal/limiter"
 	"github.com/0x0FACED/load-balancer/internal/limiter/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAllow_NewClient(t *testing.T) {
 	mockRepo := new(mocks.MockRepo)
 	cfg := limiter.Config{Capacity: 3, Rate: 1, RefillIntrerval: 100}
-	lim := limiter.NewTokenBucketLimiter(mockRepo, cfg)
+	lim := limiter.NewInMemoryLimiter(mockRepo, cfg)
 
 	mockRepo.On("Get", mock.Anything, "user1").Return(nil, nil)
 
-	allowed := lim.Allow(context.Background(), "user1")
+	allowed := lim.Allow(context.Background(), "user1", 1).Allowed
 	assert.True(t, allowed, "first token should be allowed")
 }
 
 func TestAllow_NotEnoughTokens(t *testing.T) {
 	mockRepo := new(mocks.MockRepo)
 	cfg := limiter.Config{Capacity: 2, Rate: 1, RefillIntrerval: 100}
-	lim := limiter.NewTokenBucketLimiter(mockRepo, cfg)
+	lim := limiter.NewInMemoryLimiter(mockRepo, cfg)
 
 	mockRepo.On("Get", mock.Anything, "user2").Return(nil, nil)
 
-	assert.True(t, lim.Allow(context.Background(), "user2"))
-	assert.False(t, lim.Allow(context.Background(), "user2"), "should not allow more than capacity")
+	assert.True(t, lim.Allow(context.Background(), "user2", 1).Allowed)
+	assert.True(t, lim.Allow(context.Background(), "user2", 1).Allowed)
+	assert.False(t, lim.Allow(context.Background(), "user2", 1).Allowed, "should not allow more than capacity")
+}
+
+func TestAllow_Decision(t *testing.T) {
+	cfg := limiter.Config{Capacity: 3, Rate: 2}
+	lim := limiter.NewInMemoryLimiter(nil, cfg)
+	ctx := context.Background()
+
+	d := lim.Allow(ctx, "user1", 3)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 3, d.Limit)
+	assert.Equal(t, 0, d.Remaining)
+	assert.Zero(t, d.RetryAfter)
+	assert.InDelta(t, 1500*time.Millisecond, d.Reset, float64(50*time.Millisecond), "3 tokens at 2 per second")
+
+	d = lim.Allow(ctx, "user1", 1)
+	assert.False(t, d.Allowed)
+	assert.InDelta(t, 500*time.Millisecond, d.RetryAfter, float64(50*time.Millisecond), "next token in half a second")
+}
+
+func TestAllow_Cost(t *testing.T) {
+	cfg := limiter.Config{Capacity: 5, Rate: 1}
+	lim := limiter.NewInMemoryLimiter(nil, cfg)
+	ctx := context.Background()
+
+	d := lim.Allow(ctx, "user1", 3)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining)
+
+	d = lim.Allow(ctx, "user1", 3)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining, "rejected request takes no tokens")
+	assert.InDelta(t, time.Second, d.RetryAfter, float64(50*time.Millisecond), "one token is missing")
+
+	d = lim.Allow(ctx, "user1", 6)
+	assert.False(t, d.Allowed)
+	assert.Zero(t, d.RetryAfter, "cost above the capacity is never allowed")
+
+	d = lim.Allow(ctx, "user1", 0)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 1, d.Remaining, "zero cost takes one token")
 }
 
 func TestReset(t *testing.T) {
 	mockRepo := new(mocks.MockRepo)
 	cfg := limiter.Config{Capacity: 2, Rate: 1, RefillIntrerval: 100}
-	lim := limiter.NewTokenBucketLimiter(mockRepo, cfg)
+	lim := limiter.NewInMemoryLimiter(mockRepo, cfg)
 
 	mockRepo.On("Get", mock.Anything, "user3").Return(nil, nil)
 
-	lim.Allow(context.Background(), "user3")
-	lim.Allow(context.Background(), "user3")
+	lim.Allow(context.Background(), "user3", 1)
+	lim.Allow(context.Background(), "user3", 1)
 	lim.Reset("user3")
 
-	assert.True(t, lim.Allow(context.Background(), "user3"), "token should be available after reset")
+	assert.True(t, lim.Allow(context.Background(), "user3", 1).Allowed, "token should be available after reset")
 }
 
-func TestRefillJob(t *testing.T) {
+func TestAllow_Refill(t *testing.T) {
 	mockRepo := new(mocks.MockRepo)
-	cfg := limiter.Config{Capacity: 2, Rate: 2, RefillIntrerval: 100}
-	lim := limiter.NewTokenBucketLimiter(mockRepo, cfg)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	lim.StartRefillJob(ctx)
+	cfg := limiter.Config{Capacity: 2, Rate: 2}
+	lim := limiter.NewInMemoryLimiter(mockRepo, cfg)
 	defer lim.Stop()
 
 	mockRepo.On("Get", mock.Anything, "user4").Return(nil, nil)
 	mockRepo.On("Close").Return(nil)
 
-	lim.Allow(ctx, "user4")
-	lim.Allow(ctx, "user4") // false
+	ctx := context.Background()
+	lim.Allow(ctx, "user4", 1)
+	lim.Allow(ctx, "user4", 1) // false
 
 	time.Sleep(1000 * time.Millisecond)
 
-	assert.True(t, lim.Allow(context.Background(), "user4"), "token should be refilled")
-	cancel()
+	assert.True(t, lim.Allow(ctx, "user4", 1).Allowed, "token should be refilled")
+	assert.True(t, lim.Allow(ctx, "user4", 1).Allowed)
+	assert.False(t, lim.Allow(ctx, "user4", 1).Allowed, "refill should not exceed capacity")
+}
+
+func TestAllow_FractionalRefill(t *testing.T) {
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 1, Rate: 4})
+	ctx := context.Background()
+
+	assert.True(t, lim.Allow(ctx, "user6", 1).Allowed)
+
+	// parts of a token are kept between requests
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, lim.Allow(ctx, "user6", 1).Allowed)
+	time.Sleep(150 * time.Millisecond)
+	assert.True(t, lim.Allow(ctx, "user6", 1).Allowed)
+}
+
+func TestUpdateConfig(t *testing.T) {
+	mockRepo := new(mocks.MockRepo)
+	cfg := limiter.Config{Capacity: 5, Rate: 1, RefillIntrerval: 100}
+	lim := limiter.NewInMemoryLimiter(mockRepo, cfg)
+
+	mockRepo.On("Get", mock.Anything, "user5").Return(nil, nil)
+	mockRepo.On("Get", mock.Anything, "vip").Return(&client.Client{ID: "vip", Capacity: 5, RefillRate: 1}, nil)
+
+	lim.Reset("user5")
+	lim.Allow(context.Background(), "vip", 1)
+	lim.Reset("vip")
+
+	lim.UpdateConfig(limiter.Config{Capacity: 2, Rate: 1, RefillIntrerval: 100})
+
+	assert.True(t, lim.Allow(context.Background(), "user5", 1).Allowed)
+	assert.True(t, lim.Allow(context.Background(), "user5", 1).Allowed)
+	assert.False(t, lim.Allow(context.Background(), "user5", 1).Allowed, "tokens above the new capacity should be dropped")
+
+	for range 5 {
+		assert.True(t, lim.Allow(context.Background(), "vip", 1).Allowed, "client limits should be kept")
+	}
+}
+
+func TestEviction_Idle(t *testing.T) {
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 2, Rate: 1, TTL: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lim.StartEvictionJob(ctx)
+
+	lim.Allow(ctx, "idle", 1)
+	lim.Allow(ctx, "active", 1)
+	assert.Equal(t, int64(2), lim.Stats().Buckets)
+
+	// eviction runs every 500ms, active client keeps its bucket
+	for range 8 {
+		time.Sleep(250 * time.Millisecond)
+		lim.Allow(ctx, "active", 1)
+	}
+
+	assert.Equal(t, limiter.Stats{Buckets: 1, EvictedIdle: 1}, lim.Stats())
+}
+
+func TestEviction_MaxClients(t *testing.T) {
+	// 64 shards with one bucket each
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 1, Rate: 0.001, MaxClients: 64})
+	ctx := context.Background()
+
+	for i := range 10_000 {
+		lim.Allow(ctx, "client-"+strconv.Itoa(i), 1)
+	}
+
+	stats := lim.Stats()
+	assert.LessOrEqual(t, stats.Buckets, int64(64))
+	assert.Equal(t, uint64(10_000)-uint64(stats.Buckets), stats.EvictedLRU)
+
+	// the most recently used client is kept
+	assert.False(t, lim.Allow(ctx, "client-9999", 1).Allowed, "bucket of the last client should be kept")
+}
+
+func TestEviction_LRU(t *testing.T) {
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 1, Rate: 0.001, MaxClients: 6400})
+	ctx := context.Background()
+
+	assert.True(t, lim.Allow(ctx, "hot", 1).Allowed)
+	assert.True(t, lim.Allow(ctx, "cold", 1).Allowed)
+
+	for i := range 20_000 {
+		lim.Allow(ctx, "client-"+strconv.Itoa(i), 1)
+		if i%50 == 0 {
+			require.False(t, lim.Allow(ctx, "hot", 1).Allowed, "recently used bucket should be kept")
+		}
+	}
+
+	assert.Positive(t, lim.Stats().EvictedLRU)
+	assert.True(t, lim.Allow(ctx, "cold", 1).Allowed, "evicted client starts with a new bucket")
+}
+
+const benchClients = 1_000_000
+
+func benchClientIDs() []string {
+	ids := make([]string, benchClients)
+	for i := range ids {
+		ids[i] = "client-" + strconv.Itoa(i)
+	}
+	return ids
+}
+
+// BenchmarkInMemoryLimiter_Allow spreads requests over 1M known clients.
+func BenchmarkInMemoryLimiter_Allow(b *testing.B) {
+	ids := benchClientIDs()
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 100, Rate: 10})
+	ctx := context.Background()
+	for _, id := range ids {
+		lim.Allow(ctx, id, 1)
+	}
+
+	var seed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(seed.Add(1)))
+		for pb.Next() {
+			lim.Allow(ctx, ids[r.Intn(len(ids))], 1)
+		}
+	})
+}
+
+// BenchmarkInMemoryLimiter_AllowNewClients creates buckets for 1M clients.
+func BenchmarkInMemoryLimiter_AllowNewClients(b *testing.B) {
+	ids := benchClientIDs()
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for range b.N {
+		lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 100, Rate: 10})
+
+		var next atomic.Int64
+		var wg sync.WaitGroup
+		for range runtime.GOMAXPROCS(0) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := next.Add(1) - 1; i < benchClients; i = next.Add(1) - 1 {
+					lim.Allow(ctx, ids[i], 1)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkInMemoryLimiter_AllowSameClient hits one bucket from all goroutines.
+func BenchmarkInMemoryLimiter_AllowSameClient(b *testing.B) {
+	lim := limiter.NewInMemoryLimiter(nil, limiter.Config{Capacity: 100, Rate: 10})
+	ctx := context.Background()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lim.Allow(ctx, "client", 1)
+		}
+	})
 }
@@ -0,0 +1,114 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// redisBreaker tracks health of redis for the redis limiter.
+//
+// closed: requests go to redis. After threshold errors in a row the
+// breaker opens.
+//
+// open: requests are served by the fallback without calling redis, so
+// they don't wait for a timeout each. After cooldown one request probes
+// redis: success closes the breaker, error keeps it open for another
+// cooldown.
+type redisBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	open     bool
+	openedAt time.Time
+	failures int
+	// probing is set while the probe request is in flight
+	probing bool
+
+	mu sync.Mutex
+}
+
+func newRedisBreaker(cfg FallbackConfig) *redisBreaker {
+	return &redisBreaker{
+		threshold: cfg.failureThreshold(),
+		cooldown:  cfg.cooldown(),
+	}
+}
+
+// allow reports whether the request should go to redis.
+func (b *redisBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// success records a successful redis call and reports whether
+// redis has recovered, i.e. the breaker was open.
+func (b *redisBreaker) success() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	recovered := b.open
+	b.open = false
+	b.probing = false
+	b.failures = 0
+	return recovered
+}
+
+// failure records a failed redis call and reports whether
+// the breaker has just opened.
+func (b *redisBreaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.open {
+		// failed probe, wait for another cooldown
+		b.probing = false
+		b.openedAt = time.Now()
+		return false
+	}
+
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+
+	b.open = true
+	b.openedAt = time.Now()
+	return true
+}
+
+// trip opens the breaker, redis is probed after cooldown.
+func (b *redisBreaker) trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.open = true
+	b.openedAt = time.Now()
+	b.probing = false
+}
+
+// cancel releases the probe of a request which ended without an answer
+// from redis, e.g. the client went away.
+func (b *redisBreaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *redisBreaker) setConfig(cfg FallbackConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = cfg.failureThreshold()
+	b.cooldown = cfg.cooldown()
+}
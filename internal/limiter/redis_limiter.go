@@ -2,7 +2,7 @@ package limiter
 
 import (
 	"context"
-	"fmt"
+	"sync"
 	"time"
 
 	"github.com/0x0FACED/load-balancer/internal/client"
@@ -10,58 +10,112 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// Redis Tocken Bucket limiter is a distributed limiter
-// with redis client as storage and token bucket algorithm
-type RedisTokenBucketLimiter struct {
+// RedisLimiter is a distributed limiter with redis client as storage.
+// Every algorithm is a lua script, so a request is one round trip and
+// state of a client is changed atomically.
+//
+// When redis calls fail or time out, requests are limited by the
+// in-memory fallback. The breaker keeps requests away from redis
+// until it responds again, so they don't wait for a timeout each.
+type RedisLimiter struct {
 	cl            *redis.Client
-	fallbackInMem *TokenBucketLimiter // fallback to local cache if redis is not responding
+	fallbackInMem *InMemoryLimiter // fallback to local cache if redis is not responding
+	breaker       *redisBreaker
 	logger        *zlog.ZerologLogger
 	repo          Repository
 	cfg           Config
+
+	mu sync.RWMutex
 }
 
-func NewRedisTocketBucketLimiter(
+func NewRedisLimiter(
 	client *redis.Client,
-	inMem *TokenBucketLimiter,
+	inMem *InMemoryLimiter,
 	log *zlog.ZerologLogger,
+	repo Repository,
 	cfg Config,
-) *RedisTokenBucketLimiter {
-	return &RedisTokenBucketLimiter{
+) *RedisLimiter {
+	return &RedisLimiter{
 		cl:            client,
 		fallbackInMem: inMem,
+		breaker:       newRedisBreaker(cfg.Fallback),
 		logger:        log,
+		repo:          repo,
 		cfg:           cfg,
 	}
 }
 
-func (rl *RedisTokenBucketLimiter) Allow(ctx context.Context, clientID string) bool {
-	cfg, err := rl.clientConfig(ctx, clientID)
+// NewRedisClient creates redis client from cfg,
+// zero timeouts and pool size are go-redis defaults.
+func NewRedisClient(cfg RedisConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:         cfg.Address,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		PoolSize:     cfg.PoolSize,
+		DialTimeout:  cfg.DialTimeout.Duration(),
+		ReadTimeout:  cfg.ReadTimeout.Duration(),
+		WriteTimeout: cfg.WriteTimeout.Duration(),
+	})
+}
+
+func (rl *RedisLimiter) Allow(ctx context.Context, clientID string, cost int) Decision {
+	cost = max(1, cost)
+
+	cl, err := rl.clientConfig(ctx, clientID)
 	if err != nil {
 		// log err and return or fallback to anonymous client
-		return false
+		return Decision{}
 	}
 
+	rl.mu.RLock()
+	defaults := rl.cfg
+	rl.mu.RUnlock()
+
 	// not added to db, use default settings
-	if cfg == nil {
-		cfg = &client.Client{}
+	l := defaultLimits(defaults)
+	if cl != nil {
+		l = clientLimits(cl, defaults)
+	}
 
-		cfg.ID = clientID
-		cfg.Capacity = rl.cfg.Capacity
-		cfg.RefillRate = rl.cfg.Rate
+	if !rl.breaker.allow() {
+		return rl.fallback(ctx, clientID, cost)
 	}
 
-	if rl.check(ctx,
-		clientID,
-		rl.cfg.TTL,
-		cfg,
-	) {
-		return true
+	redisCtx, cancel := context.WithTimeout(ctx, defaults.Fallback.timeout())
+	decision, err := rl.check(redisCtx, clientID, l, int(defaults.TTL), cost)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			// the request is gone, it says nothing about redis
+			rl.breaker.cancel()
+			return Decision{}
+		}
+
+		if rl.breaker.failure() {
+			rl.logger.Warn().Err(err).Msg("[RedisLimiter] redis unavailable, falling back to in-memory limiter")
+		}
+		return rl.fallback(ctx, clientID, cost)
+	}
+
+	if rl.breaker.success() {
+		rl.logger.Info().Msg("[RedisLimiter] redis recovered, using redis limiter")
+	}
+
+	return decision
+}
+
+// fallback limits the request locally while redis is unavailable,
+// without the fallback limiter requests are rejected.
+func (rl *RedisLimiter) fallback(ctx context.Context, clientID string, cost int) Decision {
+	if rl.fallbackInMem == nil {
+		return Decision{}
 	}
 
-	return false
+	return rl.fallbackInMem.Allow(ctx, clientID, cost)
 }
 
-func (rl *RedisTokenBucketLimiter) clientConfig(ctx context.Context, clientID string) (*client.Client, error) {
+func (rl *RedisLimiter) clientConfig(ctx context.Context, clientID string) (*client.Client, error) {
 	// if repo added to limiter
 	if rl.repo != nil {
 		config, err := rl.repo.Get(ctx, clientID)
@@ -76,97 +130,80 @@ func (rl *RedisTokenBucketLimiter) clientConfig(ctx context.Context, clientID st
 	return nil, nil
 }
 
-// Lua script for getting client tokens by clientID, refilling tokens
-// and calculating new tokens amount and returning 1 if Allowed, 0 otherwise.
-//
-// Its more perfect solution, because we dont need to iterate over ALL clients every tick.
-// We just refill client tokens if he does request.
-// All logic in 1 lua script.
-var script string = `
-local key = KEYS[1]
-local capacity = tonumber(ARGV[1])
-local refill_rate = tonumber(ARGV[2])
-local now = tonumber(ARGV[3])
-local ttl = tonumber(ARGV[4])
-
--- get curr state
-local tokens = redis.call('HGET', key, 'tokens')
-local last_refill = redis.call('HGET', key, 'last_refill')
-
--- init new client if there are no tokens
-if tokens == false then
-	redis.call('HSET', key, 'tokens', capacity - 1)
-	redis.call('HSET', key, 'last_refill', now)
-	redis.call('EXPIRE', key, ttl)
-	return 1 -- allowed
-end
-
-tokens = tonumber(tokens)
-last_refill = tonumber(last_refill)
-
--- refill tokens using time
-local elapsed = now - last_refill
-local tokens_to_add = math.floor(elapsed * refill_rate)
-
-if tokens_to_add > 0 then
-	tokens = math.min(capacity, tokens + tokens_to_add)
-	redis.call('HSET', key, 'last_refill', now)
-end
-
--- check and take client tokens
-if tokens > 0 then
-	tokens = tokens - 1
-	redis.call('HSET', key, 'tokens', tokens)
-	redis.call('EXPIRE', key, ttl)  -- обновляем TTL
-	return 1 -- allowed
-else
-	return 0 -- rejected
-end
-`
-
-func (rl *RedisTokenBucketLimiter) check(ctx context.Context, clientID string, ttl int, cfg *client.Client) bool {
-	key := fmt.Sprintf("rate_limit:%s", clientID)
-	now := time.Now().Unix()
-
-	result, err := rl.cl.Eval(
+// check runs the script of the client algorithm,
+// ttl is used by the token bucket only.
+func (rl *RedisLimiter) check(ctx context.Context, clientID string, l limits, ttl, cost int) (Decision, error) {
+	reply, err := redisScript(l.algorithm).Run(
 		ctx,
-		script,
-		[]string{key},
-		cfg.Capacity,
-		cfg.RefillRate,
-		now,
-		ttl,
-	).Int()
+		rl.cl,
+		[]string{redisKey(l.algorithm, clientID)},
+		redisArgs(l, time.Now(), ttl, cost)...,
+	).Int64Slice()
 	if err != nil {
-		// log err and return
-		return false
-	}
-
-	// allow
-	if result == 1 {
-		return true
+		return Decision{}, err
 	}
 
-	return false
+	return redisDecision(l, reply)
 }
 
-func (rl *RedisTokenBucketLimiter) Reset(clientID string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+func (rl *RedisLimiter) Reset(clientID string) {
+	rl.mu.RLock()
+	timeout := rl.cfg.Fallback.timeout()
+	rl.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	key := fmt.Sprintf("rate_limit:%s", clientID)
+	// the client may have used any of the algorithms
+	keys := []string{
+		redisKey(TokenBucket, clientID),
+		redisKey(SlidingWindowLog, clientID),
+		redisKey(SlidingWindowCounter, clientID),
+		redisKey(GCRA, clientID),
+	}
 
-	err := rl.cl.Del(ctx, key).Err()
+	err := rl.cl.Del(ctx, keys...).Err()
 	if err != nil {
-		// log err
+		rl.logger.Warn().Err(err).Str("client_id", clientID).Msg("[RedisLimiter] failed to reset client bucket")
+	}
+
+	if rl.fallbackInMem != nil {
+		rl.fallbackInMem.Reset(clientID)
 	}
 }
 
-func (rl *RedisTokenBucketLimiter) StartRefillJob(ctx context.Context) {
-	// no need to impl really
+// UpdateConfig replaces the default limits of the limiter and its fallback.
+// Buckets in redis are refilled with the new limits on the next request.
+func (rl *RedisLimiter) UpdateConfig(cfg Config) {
+	rl.mu.Lock()
+	rl.cfg = cfg
+	rl.mu.Unlock()
+
+	rl.breaker.setConfig(cfg.Fallback)
+	if rl.fallbackInMem != nil {
+		rl.fallbackInMem.setNodes(cfg.Fallback.Nodes)
+		rl.fallbackInMem.UpdateConfig(cfg)
+	}
+}
+
+// StartEvictionJob starts eviction of the fallback buckets,
+// buckets in redis expire by their TTL.
+func (rl *RedisLimiter) StartEvictionJob(ctx context.Context) {
+	if rl.fallbackInMem != nil {
+		rl.fallbackInMem.StartEvictionJob(ctx)
+	}
+}
+
+// Stats returns counters of the fallback buckets.
+func (rl *RedisLimiter) Stats() Stats {
+	if rl.fallbackInMem == nil {
+		return Stats{}
+	}
+
+	return rl.fallbackInMem.Stats()
 }
 
-func (rl *RedisTokenBucketLimiter) Stop() error {
+func (rl *RedisLimiter) Stop() error {
 	if rl.cl != nil {
 		// log err and return
 		return rl.cl.Close()
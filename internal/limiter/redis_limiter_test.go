@@ -0,0 +1,219 @@
+package limiter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/client"
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/load-balancer/internal/limiter/mocks"
+	"github.com/0x0FACED/zlog"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedisLimiter(t *testing.T, repo limiter.Repository, cfg limiter.Config) (limiter.RateLimitter, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cfg.Type = limiter.Redis
+
+	lim, err := limiter.New(zlog.NewTestLogger(), repo, cfg, limiter.RedisConfig{Address: mr.Addr()})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lim.Stop() })
+
+	return lim, mr
+}
+
+func TestNew_Type(t *testing.T) {
+	cfg := limiter.Config{Capacity: 1, Rate: 1, RefillIntrerval: 100}
+
+	lim, err := limiter.New(zlog.NewTestLogger(), nil, cfg, limiter.RedisConfig{})
+	require.NoError(t, err)
+	assert.IsType(t, &limiter.InMemoryLimiter{}, lim)
+
+	lim, _ = newRedisLimiter(t, nil, cfg)
+	assert.IsType(t, &limiter.RedisLimiter{}, lim)
+
+	cfg.Type = "memcached"
+	_, err = limiter.New(zlog.NewTestLogger(), nil, cfg, limiter.RedisConfig{})
+	assert.Error(t, err)
+}
+
+func TestNew_RedisUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	cfg := limiter.Config{
+		Type: limiter.Redis, Capacity: 1, Rate: 1, TTL: 60,
+		Fallback: limiter.FallbackConfig{Cooldown: 200, Timeout: 100},
+	}
+	lim, err := limiter.New(zlog.NewTestLogger(), nil, cfg, limiter.RedisConfig{Address: addr})
+	require.NoError(t, err, "limiter should start without redis")
+	assert.IsType(t, &limiter.RedisLimiter{}, lim)
+	t.Cleanup(func() { _ = lim.Stop() })
+
+	ctx := context.Background()
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed, "in-memory fallback allows while redis is down")
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+
+	mr = miniredis.NewMiniRedis()
+	require.NoError(t, mr.StartAddr(addr))
+	t.Cleanup(mr.Close)
+
+	time.Sleep(250 * time.Millisecond)
+	assert.True(t, lim.Allow(ctx, "user2", 1).Allowed)
+	assert.True(t, mr.Exists("rate_limit:user2"), "limiter switches to redis once it is up")
+}
+
+func TestRedisLimiter_Allow(t *testing.T) {
+	cfg := limiter.Config{Capacity: 2, Rate: 1, RefillIntrerval: 1000, TTL: 60}
+	lim, mr := newRedisLimiter(t, nil, cfg)
+	ctx := context.Background()
+
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed, "should not allow more than capacity")
+	assert.True(t, lim.Allow(ctx, "user2", 1).Allowed, "clients have their own buckets")
+
+	assert.True(t, mr.Exists("rate_limit:user1"))
+	assert.Equal(t, 60, int(mr.TTL("rate_limit:user1").Seconds()))
+
+	lim.Reset("user1")
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed, "token should be available after reset")
+}
+
+func TestRedisLimiter_Decision(t *testing.T) {
+	lim, _ := newRedisLimiter(t, nil, limiter.Config{Capacity: 2, Rate: 1, TTL: 60})
+	ctx := context.Background()
+
+	d := lim.Allow(ctx, "user1", 1)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Limit)
+	assert.Equal(t, 1, d.Remaining, "new client starts with the full bucket")
+
+	lim.Allow(ctx, "user1", 1)
+	d = lim.Allow(ctx, "user1", 1)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	assert.Greater(t, d.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, d.RetryAfter, time.Second, "one token is refilled per second")
+	assert.LessOrEqual(t, d.Reset, 2*time.Second)
+}
+
+func TestRedisLimiter_Cost(t *testing.T) {
+	lim, _ := newRedisLimiter(t, nil, limiter.Config{Capacity: 5, Rate: 1, TTL: 60})
+	ctx := context.Background()
+
+	d := lim.Allow(ctx, "user1", 3)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining)
+
+	d = lim.Allow(ctx, "user1", 3)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining, "rejected request takes no tokens")
+	assert.Greater(t, d.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, d.RetryAfter, time.Second, "one token is missing")
+
+	d = lim.Allow(ctx, "user1", 6)
+	assert.False(t, d.Allowed)
+	assert.Zero(t, d.RetryAfter, "cost above the capacity is never allowed")
+
+	assert.True(t, lim.Allow(ctx, "user1", 2).Allowed)
+}
+
+func TestRedisLimiter_ZeroTTL(t *testing.T) {
+	// zero ttl keeps buckets without expiration
+	lim, mr := newRedisLimiter(t, nil, limiter.Config{Capacity: 2, Rate: 1})
+	ctx := context.Background()
+
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed, "should not allow more than capacity")
+	assert.True(t, mr.Exists("rate_limit:user1"))
+	assert.Zero(t, mr.TTL("rate_limit:user1"))
+}
+
+func TestRedisLimiter_SharedBetweenInstances(t *testing.T) {
+	cfg := limiter.Config{Type: limiter.Redis, Capacity: 2, Rate: 1, RefillIntrerval: 1000, TTL: 60}
+	first, mr := newRedisLimiter(t, nil, cfg)
+
+	second, err := limiter.New(zlog.NewTestLogger(), nil, cfg, limiter.RedisConfig{Address: mr.Addr()})
+	require.NoError(t, err)
+	defer second.Stop()
+
+	ctx := context.Background()
+	assert.True(t, first.Allow(ctx, "user1", 1).Allowed)
+	assert.True(t, second.Allow(ctx, "user1", 1).Allowed)
+	assert.False(t, first.Allow(ctx, "user1", 1).Allowed, "limit is shared by all instances")
+}
+
+func TestRedisLimiter_ClientLimits(t *testing.T) {
+	mockRepo := new(mocks.MockRepo)
+	mockRepo.On("Get", mock.Anything, "vip").Return(&client.Client{ID: "vip", Capacity: 3, RefillRate: 1}, nil)
+	mockRepo.On("Close").Return(nil)
+
+	lim, _ := newRedisLimiter(t, mockRepo, limiter.Config{Capacity: 1, Rate: 1, RefillIntrerval: 1000, TTL: 60})
+	ctx := context.Background()
+
+	for range 3 {
+		assert.True(t, lim.Allow(ctx, "vip", 1).Allowed)
+	}
+	assert.False(t, lim.Allow(ctx, "vip", 1).Allowed)
+}
+
+func TestRedisLimiter_Fallback(t *testing.T) {
+	cfg := limiter.Config{
+		Capacity: 2, Rate: 1, RefillIntrerval: 1000, TTL: 60,
+		Fallback: limiter.FallbackConfig{FailureThreshold: 1, Cooldown: 200, Timeout: 100},
+	}
+	lim, mr := newRedisLimiter(t, nil, cfg)
+	ctx := context.Background()
+
+	mr.Close()
+	assert.True(t, lim.Allow(ctx, "user1", 2).Allowed, "in-memory fallback allows while redis is down")
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed, "fallback has its own limits")
+
+	require.NoError(t, mr.Restart())
+	assert.True(t, lim.Allow(ctx, "user2", 1).Allowed)
+	assert.False(t, mr.Exists("rate_limit:user2"), "redis is not probed before cooldown")
+
+	time.Sleep(250 * time.Millisecond)
+	assert.True(t, lim.Allow(ctx, "user3", 1).Allowed)
+	assert.True(t, mr.Exists("rate_limit:user3"), "limiter switches back to redis")
+}
+
+func TestRedisLimiter_FallbackNodes(t *testing.T) {
+	cfg := limiter.Config{
+		Capacity: 4, Rate: 1, RefillIntrerval: 1000, TTL: 60,
+		Fallback: limiter.FallbackConfig{Nodes: 2, FailureThreshold: 1, Cooldown: 10000},
+	}
+	lim, mr := newRedisLimiter(t, nil, cfg)
+	ctx := context.Background()
+
+	mr.Close()
+	lim.Reset("user1")
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.True(t, lim.Allow(ctx, "user1", 1).Allowed)
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed, "capacity is divided by the number of nodes")
+}
+
+func TestRedisLimiter_FallbackCanceledRequest(t *testing.T) {
+	cfg := limiter.Config{
+		Capacity: 2, Rate: 1, RefillIntrerval: 1000, TTL: 60,
+		Fallback: limiter.FallbackConfig{FailureThreshold: 1},
+	}
+	lim, mr := newRedisLimiter(t, nil, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, lim.Allow(ctx, "user1", 1).Allowed)
+
+	// canceled request is not a redis failure
+	assert.True(t, lim.Allow(context.Background(), "user1", 1).Allowed)
+	assert.True(t, mr.Exists("rate_limit:user1"))
+}
@@ -0,0 +1,247 @@
+package limiter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Every script takes cost requests at once, all or none of them, and
+// returns {allowed, remaining, retry_after, reset}: 1 if the request is
+// allowed and 0 otherwise, the number of requests allowed right now,
+// ms until the request of the same cost may be allowed (0 if it is
+// allowed or never will be) and ms until the full limit is back.
+
+// Lua script for getting client tokens by clientID, refilling tokens
+// by the time passed since the last request and taking one.
+//
+// Its more perfect solution, because we dont need to iterate over ALL clients every tick.
+// We just refill client tokens if he does request.
+// All logic in 1 lua script.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local refill_rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+local cost = tonumber(ARGV[5])
+
+-- get curr state, new client gets the full bucket
+local tokens = tonumber(redis.call('HGET', key, 'tokens')) or capacity
+local last_refill = tonumber(redis.call('HGET', key, 'last_refill')) or now
+
+-- refill tokens using time, parts of a token are kept
+local elapsed = math.max(0, now - last_refill) / 1000
+tokens = math.min(capacity, tokens + elapsed * refill_rate)
+
+-- check and take client tokens
+local allowed = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+end
+
+redis.call('HSET', key, 'tokens', string.format('%.6f', tokens), 'last_refill', string.format('%d', now))
+if ttl > 0 then
+	redis.call('EXPIRE', key, ttl)  -- обновляем TTL
+end
+
+local retry_after = 0
+local reset = 0
+if refill_rate > 0 then
+	if allowed == 0 and cost <= capacity then
+		retry_after = math.ceil((cost - tokens) / refill_rate * 1000)
+	end
+	reset = math.ceil((capacity - tokens) / refill_rate * 1000)
+end
+
+return {allowed, math.floor(tokens), retry_after, reset}
+`)
+
+// Sliding window log: a sorted set of requests scored by time in ms,
+// requests older than the window are removed before counting.
+var slidingWindowLogScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local member = ARGV[4]
+local cost = tonumber(ARGV[5])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+local retry_after = 0
+if count + cost <= limit then
+	for i = 1, cost do
+		redis.call('ZADD', key, now, member .. '-' .. i)
+	end
+	redis.call('PEXPIRE', key, window)
+	count = count + cost
+	allowed = 1
+elseif cost <= limit then
+	-- allowed once enough of the oldest requests leave the window
+	local idx = count + cost - 1 - limit
+	local oldest = redis.call('ZRANGE', key, idx, idx, 'WITHSCORES')
+	retry_after = tonumber(oldest[2]) + window - now
+end
+
+local reset = 0
+if count > 0 then
+	local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+	reset = tonumber(newest[2]) + window - now
+end
+
+return {allowed, math.max(0, limit - count), retry_after, reset}
+`)
+
+// Sliding window counter: counts of the current and the previous fixed
+// windows, the previous one is weighted by its overlap with the sliding one.
+var slidingWindowCounterScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local idx = math.floor(now / window)
+local start = tonumber(redis.call('HGET', key, 'start'))
+local curr = tonumber(redis.call('HGET', key, 'curr')) or 0
+local prev = tonumber(redis.call('HGET', key, 'prev')) or 0
+
+if start == nil or idx > start + 1 then
+	prev = 0
+	curr = 0
+	start = idx
+elseif idx == start + 1 then
+	prev = curr
+	curr = 0
+	start = idx
+end
+
+local elapsed = math.max(0, (now - start * window) / window)
+local estimate = prev * (1 - elapsed) + curr
+local window_end = (start + 1) * window
+
+local allowed = 0
+local retry_after = 0
+if estimate + cost <= limit then
+	curr = curr + cost
+	estimate = estimate + cost
+	allowed = 1
+	redis.call('HSET', key, 'start', string.format('%d', start), 'curr', curr, 'prev', prev)
+	redis.call('PEXPIRE', key, string.format('%d', window * 2))
+elseif limit - curr - cost >= 0 and prev > 0 then
+	-- in this window, once the previous one weighs less
+	local need = 1 - (limit - curr - cost) / prev
+	retry_after = math.max(1, math.ceil((need - elapsed) * window))
+elseif limit >= cost and curr > 0 then
+	-- in the next window, where the current one is the previous
+	local need = math.max(0, 1 - (limit - cost) / curr)
+	retry_after = math.ceil(window_end - now + need * window)
+end
+
+local reset = 0
+if curr > 0 then
+	reset = window_end + window - now
+elseif prev > 0 then
+	reset = window_end - now
+end
+
+return {allowed, math.max(0, math.floor(limit - estimate)), retry_after, reset}
+`)
+
+// GCRA: the key holds the theoretical arrival time of the next request
+// in ms, requests are spaced by interval with bursts of burst.
+var gcraScript = redis.NewScript(`
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local tat = tonumber(redis.call('GET', key)) or now
+if tat < now then
+	tat = now
+end
+
+local tolerance = burst * interval
+local next = tat + cost * interval
+
+local allowed = 0
+local retry_after = 0
+local wait = next - now - tolerance
+if wait > 0 then
+	if cost <= burst then
+		retry_after = math.ceil(wait)
+	end
+else
+	tat = next
+	allowed = 1
+	redis.call('SET', key, string.format('%.3f', tat), 'PX', string.format('%d', math.ceil(tat - now)))
+end
+
+local remaining = math.max(0, math.floor((tolerance - (tat - now)) / interval))
+return {allowed, remaining, retry_after, math.ceil(tat - now)}
+`)
+
+// redisKey returns the key of the client state, every algorithm
+// has its own keys as they keep different data.
+func redisKey(a Algorithm, clientID string) string {
+	if a == TokenBucket {
+		return fmt.Sprintf("rate_limit:%s", clientID)
+	}
+	return fmt.Sprintf("rate_limit:%s:%s", a, clientID)
+}
+
+// redisArgs returns arguments of the script of l.algorithm.
+func redisArgs(l limits, now time.Time, ttl, cost int) []any {
+	switch l.algorithm {
+	case SlidingWindowLog:
+		// random suffix keeps requests of the same ms apart
+		member := fmt.Sprintf("%d-%d", now.UnixNano(), rand.Uint32())
+		return []any{l.capacity, millis(l.window()), now.UnixMilli(), member, cost}
+	case SlidingWindowCounter:
+		return []any{l.capacity, millis(l.window()), now.UnixMilli(), cost}
+	case GCRA:
+		return []any{l.capacity, float64(l.interval()) / float64(time.Millisecond), now.UnixMilli(), cost}
+	default:
+		return []any{l.capacity, l.rate, now.UnixMilli(), ttl, cost}
+	}
+}
+
+func redisScript(a Algorithm) *redis.Script {
+	switch a {
+	case SlidingWindowLog:
+		return slidingWindowLogScript
+	case SlidingWindowCounter:
+		return slidingWindowCounterScript
+	case GCRA:
+		return gcraScript
+	default:
+		return tokenBucketScript
+	}
+}
+
+// redisDecision converts the reply of a script to the decision.
+func redisDecision(l limits, reply []int64) (Decision, error) {
+	if len(reply) != 4 {
+		return Decision{}, fmt.Errorf("unexpected reply of %s script: %v", l.algorithm, reply)
+	}
+
+	return Decision{
+		Allowed:    reply[0] == 1,
+		Limit:      l.capacity,
+		Remaining:  int(reply[1]),
+		RetryAfter: time.Duration(reply[2]) * time.Millisecond,
+		Reset:      time.Duration(reply[3]) * time.Millisecond,
+	}, nil
+}
+
+// millis returns d in whole ms, at least 1.
+func millis(d time.Duration) int64 {
+	return max(1, d.Milliseconds())
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/duration"
+	"github.com/0x0FACED/load-balancer/internal/pkg/validate"
+)
+
+// ProxyConfig tunes the transport shared by all proxied requests.
+// Zero values fall back to defaults.
+type ProxyConfig struct {
+	// DialTimeout limits establishing of a TCP connection to a backend.
+	DialTimeout duration.Milliseconds `json:"dial_timeout"`
+	// KeepAlive is the interval of TCP keep-alive probes.
+	KeepAlive duration.Milliseconds `json:"keep_alive"`
+	// TLSHandshakeTimeout limits the TLS handshake with https backends.
+	TLSHandshakeTimeout duration.Milliseconds `json:"tls_handshake_timeout"`
+	// ResponseHeaderTimeout limits waiting for response headers after
+	// the request is sent. Zero means no limit.
+	ResponseHeaderTimeout duration.Milliseconds `json:"response_header_timeout"`
+	// IdleConnTimeout closes idle keep-alive connections.
+	IdleConnTimeout duration.Milliseconds `json:"idle_conn_timeout"`
+	// MaxIdleConns caps idle connections to all backends.
+	MaxIdleConns int `json:"max_idle_conns"`
+	// MaxIdleConnsPerHost caps idle connections kept to one backend.
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	// MaxConnsPerHost caps all connections to one backend. Zero means no limit.
+	MaxConnsPerHost int `json:"max_conns_per_host"`
+	// HTTP2 enables HTTP/2 to https backends negotiated with ALPN.
+	HTTP2 bool `json:"http2"`
+	// H2C makes requests to all backends with HTTP/2 only, to http
+	// backends without TLS (prior knowledge). Backends must support it.
+	H2C bool `json:"h2c"`
+}
+
+// Validate checks that timeouts and limits are not negative.
+func (c ProxyConfig) Validate() error {
+	return errors.Join(
+		validate.NonNegative("dial_timeout", c.DialTimeout),
+		validate.NonNegative("keep_alive", c.KeepAlive),
+		validate.NonNegative("tls_handshake_timeout", c.TLSHandshakeTimeout),
+		validate.NonNegative("response_header_timeout", c.ResponseHeaderTimeout),
+		validate.NonNegative("idle_conn_timeout", c.IdleConnTimeout),
+		validate.NonNegative("max_idle_conns", c.MaxIdleConns),
+		validate.NonNegative("max_idle_conns_per_host", c.MaxIdleConnsPerHost),
+		validate.NonNegative("max_conns_per_host", c.MaxConnsPerHost),
+	)
+}
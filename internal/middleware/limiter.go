@@ -2,7 +2,10 @@ package middleware
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/0x0FACED/load-balancer/internal/limiter"
 	"github.com/0x0FACED/load-balancer/internal/pkg/httpcommon"
@@ -10,21 +13,62 @@ import (
 
 type RateLimiterMiddleware struct {
 	limiter limiter.RateLimitter
+	costs   []limiter.CostRule
 }
 
-func NewRateLimiterMiddleware(limiter limiter.RateLimitter) *RateLimiterMiddleware {
+// NewRateLimiterMiddleware creates the middleware, requests matching
+// one of costs take its cost of tokens, others take one token.
+func NewRateLimiterMiddleware(limiter limiter.RateLimitter, costs []limiter.CostRule) *RateLimiterMiddleware {
 	return &RateLimiterMiddleware{
 		limiter: limiter,
+		costs:   costs,
 	}
 }
 
+// Limiter rejects requests above the client limits with 429. Responses
+// get RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+// of the IETF draft, rejections also get Retry-After.
 func (m *RateLimiterMiddleware) Limiter(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		clientID := httpcommon.ClientIDFromRequest(r)
-		if !m.limiter.Allow(r.Context(), clientID) {
+		d := m.limiter.Allow(r.Context(), clientID, m.cost(r))
+		setRateLimitHeaders(w.Header(), d)
+
+		if !d.Allowed {
+			if d.RetryAfter > 0 {
+				// at least a second, zero would mean retry right away
+				w.Header().Set("Retry-After", strconv.FormatInt(max(1, seconds(d.RetryAfter)), 10))
+			}
 			httpcommon.JSONError(w, http.StatusTooManyRequests, errors.New("too many requests"))
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// cost returns tokens of the request by the first matching rule.
+func (m *RateLimiterMiddleware) cost(r *http.Request) int {
+	for _, rule := range m.costs {
+		if rule.Matches(r.Method, r.URL.Path) {
+			return rule.Cost
+		}
+	}
+	return 1
+}
+
+// setRateLimitHeaders sets headers of the decision, none if the
+// limits are unknown, e.g. the request failed before the check.
+func setRateLimitHeaders(h http.Header, d limiter.Decision) {
+	if d.Limit <= 0 {
+		return
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.FormatInt(seconds(d.Reset), 10))
+}
+
+// seconds returns d in whole seconds rounded up.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
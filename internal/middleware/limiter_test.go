@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/limiter"
+	"github.com/0x0FACED/load-balancer/internal/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// decisionLimiter returns the same decision for every request.
+type decisionLimiter struct {
+	limiter.RateLimitter
+	decision limiter.Decision
+}
+
+func (l *decisionLimiter) Allow(context.Context, string, int) limiter.Decision {
+	return l.decision
+}
+
+func newLimited(d limiter.Decision) http.Handler {
+	m := middleware.NewRateLimiterMiddleware(&decisionLimiter{decision: d}, nil)
+	return m.Limiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRateLimiter_Allowed(t *testing.T) {
+	h := newLimited(limiter.Decision{Allowed: true, Limit: 10, Remaining: 7, Reset: 1500 * time.Millisecond})
+
+	w := serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "7", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"), "reset is rounded up to seconds")
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
+
+func TestRateLimiter_Rejected(t *testing.T) {
+	h := newLimited(limiter.Decision{Limit: 10, Reset: 30 * time.Second, RetryAfter: 200 * time.Millisecond})
+
+	w := serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1", w.Header().Get("Retry-After"), "retry after is at least a second")
+}
+
+func TestRateLimiter_UnknownLimits(t *testing.T) {
+	// e.g. the clients repo failed
+	h := newLimited(limiter.Decision{})
+
+	w := serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
+
+// costLimiter records the cost of the last request.
+type costLimiter struct {
+	limiter.RateLimitter
+	cost int
+}
+
+func (l *costLimiter) Allow(_ context.Context, _ string, cost int) limiter.Decision {
+	l.cost = cost
+	return limiter.Decision{Allowed: true}
+}
+
+func TestRateLimiter_Cost(t *testing.T) {
+	lim := &costLimiter{}
+	m := middleware.NewRateLimiterMiddleware(lim, []limiter.CostRule{
+		{Method: "get", Path: "/export/*", Cost: 50},
+		{Path: "/export/*", Cost: 10},
+	})
+	h := m.Limiter(http.NewServeMux())
+
+	tests := []struct {
+		method, path string
+		cost         int
+	}{
+		{http.MethodGet, "/export/orders", 50},
+		{http.MethodPost, "/export/orders", 10},
+		{http.MethodGet, "/export/orders/1", 1},
+		{http.MethodGet, "/orders", 1},
+	}
+	for _, tt := range tests {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.cost, lim.cost, "%s %s", tt.method, tt.path)
+	}
+}
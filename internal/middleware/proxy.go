@@ -1,11 +1,11 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/0x0FACED/load-balancer/internal/balancer"
@@ -13,64 +13,159 @@ import (
 )
 
 type ProxyMiddleware struct {
-	balancer balancer.Balancer
+	balancer  balancer.Balancer
+	budget    *retryBudget
+	transport *http.Transport
 }
 
-func NewProxyMiddleware(balancer balancer.Balancer) *ProxyMiddleware {
+func NewProxyMiddleware(balancer balancer.Balancer, cfg ProxyConfig) *ProxyMiddleware {
 	return &ProxyMiddleware{
-		balancer: balancer,
+		balancer:  balancer,
+		budget:    &retryBudget{},
+		transport: NewTransport(cfg),
 	}
 }
 
+// Close closes idle upstream connections.
+func (m *ProxyMiddleware) Close() {
+	m.transport.CloseIdleConnections()
+}
+
 func (m *ProxyMiddleware) Proxy(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		backendAddr, err := m.balancer.Next()
+		h, err := m.balancer.Pick(r)
 		if err != nil {
+			if errors.Is(err, balancer.ErrNoRoute) {
+				httpcommon.JSONError(w, http.StatusNotFound, err)
+				return
+			}
 			httpcommon.JSONError(w, http.StatusServiceUnavailable, err)
 			return
 		}
 
-		backendURL, err := url.Parse(backendAddr)
-		if err != nil {
-			httpcommon.JSONError(w, http.StatusInternalServerError, err)
-			return
+		policy := h.Retry
+		if policy.MaxAttempts > 1 {
+			m.budget.deposit(budgetPercent(policy))
 		}
 
-		wrapped := &responseObserver{
-			ResponseWriter: w,
-			onFinish: func() {
-				if lcb, ok := m.balancer.(interface {
-					Release(string)
-				}); ok {
-					lcb.Release(backendAddr)
+		// body must be buffered to be sent again
+		replayable := policy.MaxAttempts > 1 && bufferBody(r, maxBodySize(policy))
+
+		var tried []string
+		for attempt := 1; ; attempt++ {
+			tried = append(tried, h.Addr())
+
+			// canRetry picks an untried backend for the next attempt, the
+			// budget is spent only if there is one
+			var next *balancer.Handle
+			canRetry := func() bool {
+				if !replayable || attempt >= policy.MaxAttempts || !m.budget.withdraw() {
+					return false
+				}
+
+				retryReq := r.WithContext(balancer.WithExcluded(r.Context(), tried...))
+				retryHandle, err := m.balancer.Pick(retryReq)
+				if err != nil {
+					// nowhere to retry, the response of this attempt is kept
+					m.budget.deposit(retryCost)
+					return false
 				}
-			},
+
+				next = retryHandle
+				return true
+			}
+
+			if err := m.proxy(w, r, h, canRetry); err == nil {
+				return
+			}
+
+			// attempt failed and nothing is written yet, try the next backend
+			h = next
+			if r.GetBody != nil {
+				r.Body, _ = r.GetBody()
+			}
 		}
-		defer wrapped.finishOnce()
+	})
+}
 
-		proxy := &httputil.ReverseProxy{
-			Director: func(req *http.Request) {
-				req.URL.Scheme = backendURL.Scheme
-				req.URL.Host = backendURL.Host
-				req.URL.Path = singleJoiningSlash(backendURL.Path, req.URL.Path)
-				req.Header.Set("X-Forwarded-Host", req.Host)
+// proxy sends r to the backend of h. If the attempt failed and canRetry
+// allows to retry it on another backend, nothing is written to w and
+// the error is returned.
+func (m *ProxyMiddleware) proxy(w http.ResponseWriter, r *http.Request, h *balancer.Handle, canRetry func() bool) error {
+	backendURL, err := url.Parse(h.Addr())
+	if err != nil {
+		h.Done(balancer.Result{Err: err})
+		httpcommon.JSONError(w, http.StatusInternalServerError, err)
+		return nil
+	}
 
-				if _, ok := req.Header["User-Agent"]; !ok {
-					req.Header.Set("User-Agent", "")
-				}
-			},
-			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-				httpcommon.JSONError(w, http.StatusServiceUnavailable, err)
-			},
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost: 100,
-				IdleConnTimeout:     90 * time.Second,
-				DisableCompression:  false,
-			},
+	var (
+		start    = time.Now()
+		proxyErr error
+		retryErr error
+	)
+
+	wrapped := &responseObserver{ResponseWriter: w, start: start}
+
+	// the backend connection is released only when the body is streamed
+	// (or the proxy panics on a broken copy), but the balancer gets the
+	// latency up to the response headers
+	defer func() {
+		if retryErr == nil {
+			h.Done(balancer.Result{
+				StatusCode: wrapped.statusCode,
+				Err:        proxyErr,
+				Duration:   wrapped.duration(),
+			})
 		}
+	}()
 
-		proxy.ServeHTTP(wrapped, r)
-	})
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = backendURL.Scheme
+			req.URL.Host = backendURL.Host
+			req.URL.Path = singleJoiningSlash(backendURL.Path, req.URL.Path)
+			req.Header.Set("X-Forwarded-Host", req.Host)
+
+			if _, ok := req.Header["User-Agent"]; !ok {
+				req.Header.Set("User-Agent", "")
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if retryableStatus(h.Retry, r.Method, resp.StatusCode) && canRetry() {
+				// error handler is called with this error, the response is discarded
+				return &statusError{code: resp.StatusCode}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var statusErr *statusError
+			if errors.As(err, &statusErr) || (isConnectError(err) && canRetry()) {
+				retryErr = err
+				return
+			}
+
+			proxyErr = err
+			httpcommon.JSONError(w, http.StatusServiceUnavailable, err)
+		},
+		Transport: m.transport,
+	}
+
+	proxy.ServeHTTP(wrapped, r)
+
+	if retryErr != nil {
+		res := balancer.Result{Err: retryErr, Duration: time.Since(start)}
+
+		var statusErr *statusError
+		if errors.As(retryErr, &statusErr) {
+			res = balancer.Result{StatusCode: statusErr.code, Duration: time.Since(start)}
+		}
+
+		h.Done(res)
+		return retryErr
+	}
+
+	return nil
 }
 
 func singleJoiningSlash(a, b string) string {
@@ -85,26 +180,42 @@ func singleJoiningSlash(a, b string) string {
 	return a + b
 }
 
+// responseObserver records the response status code and the time
+// since start when the response headers are written.
 type responseObserver struct {
 	http.ResponseWriter
-	onFinish func()
-	once     sync.Once
+	start      time.Time
+	statusCode int
+	headerTime time.Duration
 }
 
 func (o *responseObserver) Write(b []byte) (int, error) {
-	o.finishOnce()
+	o.observe(http.StatusOK)
 	return o.ResponseWriter.Write(b)
 }
 
 func (o *responseObserver) WriteHeader(statusCode int) {
-	o.finishOnce()
+	o.observe(statusCode)
 	o.ResponseWriter.WriteHeader(statusCode)
 }
 
-func (o *responseObserver) finishOnce() {
-	o.once.Do(func() {
-		if o.onFinish != nil {
-			o.onFinish()
-		}
-	})
+// Unwrap lets http.ResponseController flush streamed responses.
+func (o *responseObserver) Unwrap() http.ResponseWriter {
+	return o.ResponseWriter
+}
+
+func (o *responseObserver) observe(statusCode int) {
+	if o.statusCode == 0 {
+		o.statusCode = statusCode
+		o.headerTime = time.Since(o.start)
+	}
+}
+
+// duration returns the time until the response headers,
+// or the time since start if they are not written.
+func (o *responseObserver) duration() time.Duration {
+	if o.statusCode == 0 {
+		return time.Since(o.start)
+	}
+	return o.headerTime
 }
@@ -0,0 +1,206 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/middleware"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadBackend returns address nobody listens on.
+func deadBackend() string {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+// echoBackend responds with the request body and counts requests.
+func echoBackend(t *testing.T, hits *atomic.Int64) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = io.Copy(w, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// statusBackend always responds with code and counts requests.
+func statusBackend(t *testing.T, code int, hits *atomic.Int64) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func newProxy(t *testing.T, cfg balancer.Config) http.Handler {
+	t.Helper()
+
+	cfg.Type = balancer.RoundRobin
+	bal, err := balancer.New(zlog.NewTestLogger(), cfg)
+	require.NoError(t, err)
+
+	for _, addr := range cfg.Backends {
+		bal.SetAlive(addr, true)
+	}
+
+	return middleware.NewProxyMiddleware(bal, middleware.ProxyConfig{}).Proxy(http.NewServeMux())
+}
+
+func serve(h http.Handler, method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, "/", strings.NewReader(body)))
+	return w
+}
+
+func TestProxy_RetryConnectError(t *testing.T) {
+	var hits atomic.Int64
+	h := newProxy(t, balancer.Config{
+		Backends: []string{deadBackend(), echoBackend(t, &hits)},
+		Retry:    balancer.RetryConfig{MaxAttempts: 2, BudgetPercent: 100},
+	})
+
+	// round robin picks the dead backend first, body is replayed on the second one
+	w := serve(h, http.MethodPost, `{"id":1}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"id":1}`, w.Body.String())
+	assert.Equal(t, int64(1), hits.Load())
+}
+
+func TestProxy_NoRetryByDefault(t *testing.T) {
+	var hits atomic.Int64
+	h := newProxy(t, balancer.Config{
+		Backends: []string{deadBackend(), echoBackend(t, &hits)},
+	})
+
+	w := serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, int64(0), hits.Load())
+}
+
+func TestProxy_RetryOnStatus(t *testing.T) {
+	var failing, healthy atomic.Int64
+	h := newProxy(t, balancer.Config{
+		Backends: []string{
+			statusBackend(t, http.StatusServiceUnavailable, &failing),
+			statusBackend(t, http.StatusOK, &healthy),
+		},
+		Retry: balancer.RetryConfig{
+			MaxAttempts:   2,
+			RetryOnStatus: []int{http.StatusServiceUnavailable},
+			BudgetPercent: 100,
+		},
+	})
+
+	// idempotent request is retried on the healthy backend
+	w := serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1), failing.Load())
+	assert.Equal(t, int64(1), healthy.Load())
+
+	// non-idempotent request is not retried on status
+	w = serve(h, http.MethodPost, "data")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, int64(2), failing.Load())
+	assert.Equal(t, int64(1), healthy.Load())
+}
+
+func TestProxy_RetryKeepsUpstreamResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = io.WriteString(w, "upstream")
+	}))
+	t.Cleanup(upstream.Close)
+
+	h := newProxy(t, balancer.Config{
+		Backends: []string{upstream.URL},
+		Retry: balancer.RetryConfig{
+			MaxAttempts:   2,
+			RetryOnStatus: []int{http.StatusBadGateway},
+			BudgetPercent: 100,
+		},
+	})
+
+	// there is no other backend, the response is not discarded
+	w := serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "upstream", w.Body.String())
+}
+
+func TestProxy_RetryBodyTooLarge(t *testing.T) {
+	var hits atomic.Int64
+	h := newProxy(t, balancer.Config{
+		Backends: []string{deadBackend(), echoBackend(t, &hits)},
+		Retry:    balancer.RetryConfig{MaxAttempts: 2, BudgetPercent: 100, MaxBodySize: 4},
+	})
+
+	w := serve(h, http.MethodPost, "too large to replay")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, int64(0), hits.Load())
+}
+
+func TestProxy_RetryBudget(t *testing.T) {
+	var hits atomic.Int64
+	h := newProxy(t, balancer.Config{
+		Backends: []string{deadBackend(), echoBackend(t, &hits)},
+		Retry:    balancer.RetryConfig{MaxAttempts: 2, BudgetPercent: 50},
+	})
+
+	// every request deposits 50% of a retry
+	w := serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "budget is empty")
+
+	w = serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code, "alive backend")
+
+	w = serve(h, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code, "retried on the alive backend")
+	assert.Equal(t, int64(2), hits.Load())
+}
+
+func TestProxy_DrainWaitsForBody(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "head ")
+		http.NewResponseController(w).Flush()
+		<-release
+		_, _ = io.WriteString(w, "tail")
+	}))
+	t.Cleanup(upstream.Close)
+
+	log := zlog.NewTestLogger()
+	bal := balancer.NewRoundRobinBalancer(log, balancer.Config{Backends: []string{upstream.URL}})
+	bal.SetAlive(upstream.URL, true)
+	bk := bal.Backends()[0]
+
+	lb := httptest.NewServer(middleware.NewProxyMiddleware(bal, middleware.ProxyConfig{}).Proxy(http.NewServeMux()))
+	t.Cleanup(lb.Close)
+
+	resp, err := http.Get(lb.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// headers are sent, the body is still streamed
+	err = balancer.Drain(context.Background(), log, bk, 200*time.Millisecond)
+	assert.ErrorIs(t, err, balancer.ErrDrainTimeout)
+	assert.Equal(t, 1, bk.Connections())
+
+	close(release)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "head tail", string(body))
+
+	require.NoError(t, balancer.Drain(context.Background(), log, bk, time.Second))
+}
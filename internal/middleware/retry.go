@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+)
+
+const (
+	defaultRetryBudgetPercent = 20
+	defaultMaxRetryBodySize   = 64 << 10
+
+	// retryCost is the budget balance taken by one retry,
+	// every request deposits its budget percent
+	retryCost = 100
+	// maxRetryBalance caps the saved budget, so a long quiet period
+	// doesn't allow a retry storm later
+	maxRetryBalance = 100 * retryCost
+)
+
+// statusError is returned from ModifyResponse to retry the response
+// with the retryable status code.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("backend responded with %d", e.code)
+}
+
+// retryBudget limits the share of retried requests: every request deposits
+// its budget percent and every retry withdraws 100.
+type retryBudget struct {
+	balance atomic.Int64
+}
+
+func (b *retryBudget) deposit(percent int) {
+	if b.balance.Add(int64(percent)) > maxRetryBalance {
+		b.balance.Store(maxRetryBalance)
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	for {
+		curr := b.balance.Load()
+		if curr < retryCost {
+			return false
+		}
+		if b.balance.CompareAndSwap(curr, curr-retryCost) {
+			return true
+		}
+	}
+}
+
+func budgetPercent(cfg balancer.RetryConfig) int {
+	if cfg.BudgetPercent <= 0 {
+		return defaultRetryBudgetPercent
+	}
+	return cfg.BudgetPercent
+}
+
+func maxBodySize(cfg balancer.RetryConfig) int64 {
+	if cfg.MaxBodySize <= 0 {
+		return defaultMaxRetryBodySize
+	}
+	return cfg.MaxBodySize
+}
+
+// bufferBody reads body of r into memory if it is not larger than limit
+// and sets r.GetBody to replay it. Reports whether r can be replayed.
+func bufferBody(r *http.Request, limit int64) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+
+	if r.ContentLength > limit {
+		return false
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil || int64(len(buf)) > limit {
+		// put back what was read, the request is proxied once
+		r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+		return false
+	}
+
+	_ = r.Body.Close()
+
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	r.Body, _ = r.GetBody()
+
+	return true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isConnectError reports whether the request failed before it was sent,
+// so it is safe to retry it for any method.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryableStatus reports whether the response with code can be retried.
+func retryableStatus(cfg balancer.RetryConfig, method string, code int) bool {
+	return isIdempotent(method) && slices.Contains(cfg.RetryOnStatus, code)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/duration"
+)
+
+const (
+	defaultDialTimeout         = 5 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultTLSHandshakeTimeout = 5 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConns        = 1000
+	defaultMaxIdleConnsPerHost = 100
+)
+
+// NewTransport creates the upstream transport from cfg. It is long-lived
+// and shared by all requests, so keep-alive connections to backends are reused.
+func NewTransport(cfg ProxyConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(cfg.DialTimeout, defaultDialTimeout),
+		KeepAlive: durationOrDefault(cfg.KeepAlive, defaultKeepAlive),
+	}
+
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   durationOrDefault(cfg.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout.Duration(),
+		IdleConnTimeout:       durationOrDefault(cfg.IdleConnTimeout, defaultIdleConnTimeout),
+		MaxIdleConns:          intOrDefault(cfg.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   intOrDefault(cfg.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+	}
+
+	if cfg.H2C {
+		// without HTTP/1 in the set http backends are dialed with h2c
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		protocols.SetHTTP2(true)
+		t.Protocols = protocols
+	}
+
+	return t
+}
+
+func durationOrDefault(d duration.Milliseconds, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d.Duration()
+}
+
+func intOrDefault(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,123 @@
+package middleware_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/balancer"
+	"github.com/0x0FACED/load-balancer/internal/middleware"
+	"github.com/0x0FACED/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend responds with 200 and counts accepted TCP connections.
+func countingBackend(tb testing.TB, conns *atomic.Int64) string {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	tb.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func newBenchProxy(tb testing.TB, cfg middleware.ProxyConfig, backends ...string) http.Handler {
+	tb.Helper()
+
+	bal, err := balancer.New(zlog.NewTestLogger(), balancer.Config{
+		Type:     balancer.RoundRobin,
+		Backends: backends,
+	})
+	require.NoError(tb, err)
+
+	for _, addr := range backends {
+		bal.SetAlive(addr, true)
+	}
+
+	m := middleware.NewProxyMiddleware(bal, cfg)
+	tb.Cleanup(m.Close)
+	return m.Proxy(http.NewServeMux())
+}
+
+func TestProxy_ReusesConnections(t *testing.T) {
+	var conns atomic.Int64
+	h := newBenchProxy(t, middleware.ProxyConfig{}, countingBackend(t, &conns))
+
+	for range 50 {
+		w := serve(h, http.MethodGet, "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, int64(1), conns.Load(), "sequential requests should reuse one connection")
+}
+
+func TestProxy_MaxConnsPerHost(t *testing.T) {
+	var conns atomic.Int64
+	h := newBenchProxy(t, middleware.ProxyConfig{MaxConnsPerHost: 2}, countingBackend(t, &conns))
+
+	done := make(chan struct{})
+	for range 20 {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			serve(h, http.MethodGet, "")
+		}()
+	}
+	for range 20 {
+		<-done
+	}
+
+	assert.LessOrEqual(t, conns.Load(), int64(2))
+}
+
+func TestNewTransport_Defaults(t *testing.T) {
+	tr := middleware.NewTransport(middleware.ProxyConfig{})
+	assert.Equal(t, 100, tr.MaxIdleConnsPerHost)
+	assert.Zero(t, tr.MaxConnsPerHost)
+	assert.Zero(t, tr.ResponseHeaderTimeout)
+
+	tr = middleware.NewTransport(middleware.ProxyConfig{
+		MaxIdleConnsPerHost:   10,
+		ResponseHeaderTimeout: 2000,
+		HTTP2:                 true,
+	})
+	assert.Equal(t, 10, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, 2*time.Second, tr.ResponseHeaderTimeout)
+	assert.True(t, tr.ForceAttemptHTTP2)
+}
+
+// BenchmarkProxy reports new upstream connections per request:
+// shared transport keeps it close to zero.
+func BenchmarkProxy(b *testing.B) {
+	b.Run("sequential", func(b *testing.B) {
+		var conns atomic.Int64
+		h := newBenchProxy(b, middleware.ProxyConfig{}, countingBackend(b, &conns))
+
+		b.ReportAllocs()
+		for b.Loop() {
+			serve(h, http.MethodGet, "")
+		}
+		b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+	})
+
+	b.Run("parallel", func(b *testing.B) {
+		var conns atomic.Int64
+		h := newBenchProxy(b, middleware.ProxyConfig{}, countingBackend(b, &conns), countingBackend(b, &conns))
+
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				serve(h, http.MethodGet, "")
+			}
+		})
+		b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+	})
+}
@@ -0,0 +1,92 @@
+// Package duration provides durations for config files. They are decoded
+// from strings like "2s" or "1m30s" and, as before, from plain numbers
+// in the unit of the type.
+package duration
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Milliseconds is a duration stored as a number of milliseconds.
+type Milliseconds int64
+
+func (d Milliseconds) Duration() time.Duration {
+	return time.Duration(d) * time.Millisecond
+}
+
+func (d Milliseconds) String() string {
+	return d.Duration().String()
+}
+
+func (d Milliseconds) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Milliseconds) UnmarshalJSON(data []byte) error {
+	v, err := unmarshal(data, time.Millisecond)
+	if err != nil {
+		return err
+	}
+	*d = Milliseconds(v)
+	return nil
+}
+
+// Seconds is a duration stored as a number of seconds.
+type Seconds int64
+
+func (d Seconds) Duration() time.Duration {
+	return time.Duration(d) * time.Second
+}
+
+func (d Seconds) String() string {
+	return d.Duration().String()
+}
+
+func (d Seconds) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Seconds) UnmarshalJSON(data []byte) error {
+	v, err := unmarshal(data, time.Second)
+	if err != nil {
+		return err
+	}
+	*d = Seconds(v)
+	return nil
+}
+
+// unmarshal decodes a number of units or a duration string
+// which is a whole number of units.
+func unmarshal(data []byte, unit time.Duration) (int64, error) {
+	if len(data) == 0 || data[0] != '"' {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return 0, fmt.Errorf("invalid duration %s: expected a string like \"2s\" or a whole number of %s", data, unitName(unit))
+		}
+		return n, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return 0, err
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d%unit != 0 {
+		return 0, fmt.Errorf("invalid duration %q: must be a whole number of %s", s, unitName(unit))
+	}
+
+	return int64(d / unit), nil
+}
+
+func unitName(unit time.Duration) string {
+	if unit == time.Second {
+		return "seconds"
+	}
+	return "milliseconds"
+}
@@ -0,0 +1,42 @@
+package duration_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/0x0FACED/load-balancer/internal/pkg/duration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMilliseconds(t *testing.T) {
+	var v struct {
+		Str duration.Milliseconds `json:"str"`
+		Num duration.Milliseconds `json:"num"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"str": "1m30s", "num": 2000}`), &v))
+
+	assert.Equal(t, 90*time.Second, v.Str.Duration())
+	assert.Equal(t, 2*time.Second, v.Num.Duration())
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"str": "1m30s", "num": "2s"}`, string(data))
+
+	var d duration.Milliseconds
+	assert.Error(t, json.Unmarshal([]byte(`"2x"`), &d))
+	assert.Error(t, json.Unmarshal([]byte(`"10us"`), &d), "less than a millisecond")
+	assert.Error(t, json.Unmarshal([]byte(`1.5`), &d))
+}
+
+func TestSeconds(t *testing.T) {
+	var d duration.Seconds
+	require.NoError(t, json.Unmarshal([]byte(`3600`), &d))
+	assert.Equal(t, time.Hour, d.Duration())
+
+	require.NoError(t, json.Unmarshal([]byte(`"24h"`), &d))
+	assert.Equal(t, duration.Seconds(86400), d)
+
+	assert.Error(t, json.Unmarshal([]byte(`"1500ms"`), &d))
+}
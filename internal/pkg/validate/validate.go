@@ -0,0 +1,117 @@
+// Package validate reports config errors with JSON paths of the fields,
+// like "pools.api.backends[0]: invalid URL".
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Error is a problem with the config field at Path.
+type Error struct {
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Field attaches path to err. Errors which already have a path,
+// also joined ones, get path as the prefix of it. Nil err gives nil.
+func Field(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		res := make([]error, len(errs))
+		for i, e := range errs {
+			res[i] = Field(path, e)
+		}
+		return errors.Join(res...)
+	}
+
+	if fe, ok := err.(*Error); ok {
+		return &Error{Path: join(path, fe.Path), Err: fe.Err}
+	}
+
+	return &Error{Path: path, Err: err}
+}
+
+// Errorf returns the error of the field at path.
+func Errorf(path, format string, args ...any) error {
+	return &Error{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+// Index returns the path of the element i of the list at path.
+func Index(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// Number is a config value that can be range checked.
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+// NonNegative checks that v >= 0.
+func NonNegative[T Number](path string, v T) error {
+	if v < 0 {
+		return Errorf(path, "must not be negative, got %v", v)
+	}
+	return nil
+}
+
+// Positive checks that v > 0.
+func Positive[T Number](path string, v T) error {
+	if v <= 0 {
+		return Errorf(path, "must be positive, got %v", v)
+	}
+	return nil
+}
+
+// Range checks that min <= v <= max.
+func Range[T Number](path string, v, min, max T) error {
+	if v < min || v > max {
+		return Errorf(path, "must be in [%v, %v], got %v", min, max, v)
+	}
+	return nil
+}
+
+// URL checks that s is an absolute http or https URL with a host.
+func URL(path, s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Errorf(path, "scheme must be http or https: %q", s)
+	}
+	if u.Host == "" {
+		return Errorf(path, "host is empty: %q", s)
+	}
+	return nil
+}
+
+func join(path, sub string) string {
+	switch {
+	case path == "":
+		return sub
+	case sub == "":
+		return path
+	case strings.HasPrefix(sub, "["):
+		return path + sub
+	default:
+		return path + "." + sub
+	}
+}
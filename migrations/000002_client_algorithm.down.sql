@@ -0,0 +1,3 @@
+ALTER TABLE clients
+    DROP COLUMN IF EXISTS algorithm,
+    DROP COLUMN IF EXISTS window_ms;
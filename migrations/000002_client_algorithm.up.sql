@@ -0,0 +1,3 @@
+ALTER TABLE clients
+    ADD COLUMN IF NOT EXISTS algorithm TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS window_ms INTEGER NOT NULL DEFAULT 0;